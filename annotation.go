@@ -0,0 +1,114 @@
+package opentsdb
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Annotation marks event, like deploy or incident, on time series with TSUID
+// or on all of them, if TSUID is empty:
+// http://opentsdb.net/docs/build/html/api_http/annotation/index.html
+type Annotation struct {
+	// StartTime and EndTime are in seconds, EndTime is zero for events
+	// without duration
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime,omitempty"`
+	// TSUID is empty for global annotation
+	TSUID       string            `json:"tsuid,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Notes       string            `json:"notes,omitempty"`
+	Custom      map[string]string `json:"custom,omitempty"`
+}
+
+// AnnotationDeletion is body of bulk delete request, it matches annotations
+// of TSUIDs or global ones, that start between StartTime and EndTime
+type AnnotationDeletion struct {
+	TSUIDs    []string `json:"tsuids,omitempty"`
+	StartTime int64    `json:"startTime"`
+	// EndTime is now if it's zero
+	EndTime int64 `json:"endTime,omitempty"`
+	Global  bool  `json:"global,omitempty"`
+}
+
+// Annotation returns annotation of tsuid, or global one if tsuid is empty,
+// that starts at startTime
+func (qc *QueryClient) Annotation(ctx context.Context, startTime int64, tsuid string) (*Annotation, error) {
+	annotation := &Annotation{}
+	if err := qc.do(ctx, "GET", "api/annotation", annotationParams(startTime, tsuid), nil, annotation); err != nil {
+		return nil, err
+	}
+	return annotation, nil
+}
+
+// CreateAnnotation stores annotation. If it already exists, only fields of a
+// that are not empty are changed. Stored annotation is returned
+func (qc *QueryClient) CreateAnnotation(ctx context.Context, a *Annotation) (*Annotation, error) {
+	stored := &Annotation{}
+	err := qc.do(ctx, "POST", "api/annotation", nil, a, stored)
+	if isNotModified(err) {
+		return qc.Annotation(ctx, a.StartTime, a.TSUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// UpdateAnnotation replaces existing annotation with a, fields that are empty
+// in a are cleared
+func (qc *QueryClient) UpdateAnnotation(ctx context.Context, a *Annotation) (*Annotation, error) {
+	stored := &Annotation{}
+	err := qc.do(ctx, "PUT", "api/annotation", nil, a, stored)
+	if isNotModified(err) {
+		return qc.Annotation(ctx, a.StartTime, a.TSUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// DeleteAnnotation deletes annotation with StartTime and TSUID of a
+func (qc *QueryClient) DeleteAnnotation(ctx context.Context, a *Annotation) error {
+	return qc.do(ctx, "DELETE", "api/annotation", annotationParams(a.StartTime, a.TSUID), nil, nil)
+}
+
+// CreateAnnotations is bulk version of CreateAnnotation
+func (qc *QueryClient) CreateAnnotations(ctx context.Context, annotations []*Annotation) ([]*Annotation, error) {
+	var stored []*Annotation
+	if err := qc.do(ctx, "POST", "api/annotation/bulk", nil, annotations, &stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// UpdateAnnotations is bulk version of UpdateAnnotation
+func (qc *QueryClient) UpdateAnnotations(ctx context.Context, annotations []*Annotation) ([]*Annotation, error) {
+	var stored []*Annotation
+	if err := qc.do(ctx, "PUT", "api/annotation/bulk", nil, annotations, &stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// DeleteAnnotations deletes all annotations that match req and returns how
+// many of them were deleted
+func (qc *QueryClient) DeleteAnnotations(ctx context.Context, req *AnnotationDeletion) (int64, error) {
+	var resp struct {
+		TotalDeleted int64 `json:"totalDeleted"`
+	}
+	if err := qc.do(ctx, "DELETE", "api/annotation/bulk", nil, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalDeleted, nil
+}
+
+func annotationParams(startTime int64, tsuid string) url.Values {
+	params := url.Values{}
+	params.Set("start_time", strconv.FormatInt(startTime, 10))
+	if tsuid != "" {
+		params.Set("tsuid", tsuid)
+	}
+	return params
+}
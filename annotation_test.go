@@ -0,0 +1,106 @@
+package opentsdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotation(t *testing.T) {
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/annotation", r.URL.Path)
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case "GET", "DELETE":
+			assert.Equal(t, "1369141261", r.URL.Query().Get("start_time"))
+			assert.Equal(t, "000001000001000001", r.URL.Query().Get("tsuid"))
+		case "POST", "PUT":
+			var a Annotation
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+			assert.Equal(t, "deploy v1.2", a.Description)
+		}
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, `{"tsuid":"000001000001000001","description":"deploy v1.2","notes":"",`+
+			`"custom":{"owner":"ops"},"endTime":0,"startTime":1369141261}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	a := &Annotation{StartTime: 1369141261, TSUID: "000001000001000001", Description: "deploy v1.2"}
+
+	stored, err := qc.CreateAnnotation(ctx, a)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "ops"}, stored.Custom)
+
+	_, err = qc.UpdateAnnotation(ctx, a)
+	assert.NoError(t, err)
+
+	stored, err = qc.Annotation(ctx, a.StartTime, a.TSUID)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1369141261, stored.StartTime)
+
+	assert.NoError(t, qc.DeleteAnnotation(ctx, a))
+	assert.Equal(t, []string{"POST", "PUT", "GET", "DELETE"}, methods)
+}
+
+func TestAnnotationNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query().Get("tsuid"))
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":404,"message":"Unable to locate annotation in storage"}}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	_, err = qc.Annotation(context.Background(), 1369141261, "")
+	assert.IsType(t, &APIError{}, err)
+	assert.Equal(t, http.StatusNotFound, err.(*APIError).StatusCode)
+}
+
+func TestAnnotationsBulk(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/annotation/bulk", r.URL.Path)
+		switch r.Method {
+		case "POST":
+			var annotations []*Annotation
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&annotations))
+			assert.Len(t, annotations, 2)
+			json.NewEncoder(w).Encode(annotations)
+		case "DELETE":
+			var req map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, map[string]interface{}{"startTime": 1369141261.0, "global": true}, req)
+			fmt.Fprint(w, `{"tsuids":null,"global":true,"startTime":1369141261000,"endTime":0,"totalDeleted":3}`)
+		}
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	stored, err := qc.CreateAnnotations(ctx, []*Annotation{
+		{StartTime: 1369141261, Description: "deploy"},
+		{StartTime: 1369141261, EndTime: 1369141861, TSUID: "000001000001000001", Description: "incident"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.Equal(t, "incident", stored[1].Description)
+
+	deleted, err := qc.DeleteAnnotations(ctx, &AnnotationDeletion{StartTime: 1369141261, Global: true})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, deleted)
+}
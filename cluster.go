@@ -0,0 +1,187 @@
+package opentsdb
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyEndpoints is returned by Cluster's Sender when all endpoints
+// are unhealthy and none of them is due for probe yet. It's retryable
+var ErrNoHealthyEndpoints error = unavailableError("no healthy endpoints")
+
+type unavailableError string
+
+func (err unavailableError) Error() string   { return string(err) }
+func (err unavailableError) Retryable() bool { return true }
+
+// BalancePolicy defines how Cluster picks endpoint for batch
+type BalancePolicy int
+
+const (
+	// RoundRobin sends batches to healthy endpoints in turn
+	RoundRobin BalancePolicy = iota
+	// LeastInflight sends batch to healthy endpoint with least number of
+	// requests in flight
+	LeastInflight
+)
+
+// Cluster keeps list of TSD endpoints with their health and load. It's shared
+// by all workers, while every worker gets its own Sender for each endpoint
+// from Cluster.Sender. Endpoint is marked unhealthy after MaxFailures
+// consecutive failures and is probed again once in ProbeInterval.
+// Cluster is safe for concurrent use
+type Cluster struct {
+	// Policy is how endpoint for batch is picked
+	Policy BalancePolicy
+	// MaxFailures is number of consecutive failures after which endpoint is
+	// marked unhealthy
+	MaxFailures int64
+	// ProbeInterval is how often unhealthy endpoint is tried again
+	ProbeInterval time.Duration
+
+	endpoints []*endpoint
+	next      uint64
+}
+
+type endpoint struct {
+	addr     string
+	inflight int64
+	failures int64
+	// probeAt is unix time in nanoseconds when unhealthy endpoint should be
+	// tried again, zero for healthy endpoint
+	probeAt int64
+}
+
+// NewCluster creates Cluster for given endpoints
+func NewCluster(addrs []string) *Cluster {
+	cluster := &Cluster{
+		MaxFailures:   3,
+		ProbeInterval: 5 * time.Second,
+	}
+	for _, addr := range addrs {
+		cluster.endpoints = append(cluster.endpoints, &endpoint{addr: addr})
+	}
+	return cluster
+}
+
+// Healthy returns addresses of endpoints that are currently healthy
+func (cluster *Cluster) Healthy() []string {
+	var addrs []string
+	for _, e := range cluster.endpoints {
+		if atomic.LoadInt64(&e.probeAt) == 0 {
+			addrs = append(addrs, e.addr)
+		}
+	}
+	return addrs
+}
+
+// Sender returns Sender for one worker, that balances batches across
+// endpoints using Sender from newSender for each of them. Failed batch is
+// tried on other endpoints, until it's sent or error is not retryable
+func (cluster *Cluster) Sender(newSender func(addr string) Sender) Sender {
+	b := &balancer{cluster: cluster}
+	for _, e := range cluster.endpoints {
+		b.senders = append(b.senders, newSender(e.addr))
+	}
+	return b
+}
+
+// pick returns indexes of endpoints in order they should be tried. Endpoint
+// that is due for probe goes first, so it's actually probed. Unhealthy
+// endpoints that are not due for probe are skipped, so result is empty if
+// all of them are down
+func (cluster *Cluster) pick() []int {
+	now := time.Now().UnixNano()
+	var probes, healthy []int
+	for i, e := range cluster.endpoints {
+		probeAt := atomic.LoadInt64(&e.probeAt)
+		switch {
+		case probeAt == 0:
+			healthy = append(healthy, i)
+		case probeAt <= now:
+			// only one worker will get to probe it
+			next := now + int64(cluster.ProbeInterval)
+			if atomic.CompareAndSwapInt64(&e.probeAt, probeAt, next) {
+				probes = append(probes, i)
+			}
+		}
+	}
+	switch cluster.Policy {
+	case LeastInflight:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return atomic.LoadInt64(&cluster.endpoints[healthy[i]].inflight) <
+				atomic.LoadInt64(&cluster.endpoints[healthy[j]].inflight)
+		})
+	default:
+		if len(healthy) > 0 {
+			n := int(atomic.AddUint64(&cluster.next, 1) % uint64(len(healthy)))
+			healthy = append(healthy[n:], healthy[:n]...)
+		}
+	}
+	return append(probes, healthy...)
+}
+
+func (cluster *Cluster) succeeded(e *endpoint) {
+	atomic.StoreInt64(&e.failures, 0)
+	atomic.StoreInt64(&e.probeAt, 0)
+}
+
+func (cluster *Cluster) failed(e *endpoint) {
+	if atomic.AddInt64(&e.failures, 1) < cluster.MaxFailures {
+		return
+	}
+	next := time.Now().Add(cluster.ProbeInterval).UnixNano()
+	if atomic.LoadInt64(&e.probeAt) < next {
+		atomic.StoreInt64(&e.probeAt, next)
+	}
+}
+
+// balancer is Sender of one worker for Cluster
+type balancer struct {
+	cluster *Cluster
+	senders []Sender
+}
+
+func (b *balancer) Send(ctx context.Context, batch DataPoints) error {
+	err := ErrNoHealthyEndpoints
+	for _, i := range b.cluster.pick() {
+		e := b.cluster.endpoints[i]
+
+		atomic.AddInt64(&e.inflight, 1)
+		err = b.senders[i].Send(ctx, batch)
+		atomic.AddInt64(&e.inflight, -1)
+
+		if err == nil {
+			b.cluster.succeeded(e)
+			return nil
+		}
+		if !IsRetryable(err) {
+			// endpoint is fine, it's batch that is bad
+			if _, ok := err.(*RejectedError); ok {
+				b.cluster.succeeded(e)
+			}
+			return err
+		}
+		b.cluster.failed(e)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// Close closes senders that implement io.Closer
+func (b *balancer) Close() error {
+	var err error
+	for _, sender := range b.senders {
+		if closer, ok := sender.(io.Closer); ok {
+			if e := closer.Close(); e != nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
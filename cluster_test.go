@@ -0,0 +1,166 @@
+package opentsdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterRoundRobin(t *testing.T) {
+	counts := map[string]*int64{"a": new(int64), "b": new(int64), "c": new(int64)}
+	cluster := NewCluster([]string{"a", "b", "c"})
+	sender := cluster.Sender(func(addr string) Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			atomic.AddInt64(counts[addr], 1)
+			return nil
+		})
+	})
+
+	for i := 0; i < 9; i++ {
+		assert.NoError(t, sender.Send(context.Background(), dps))
+	}
+	for addr, count := range counts {
+		assert.EqualValues(t, 3, *count, addr)
+	}
+}
+
+func TestClusterFailover(t *testing.T) {
+	var down int32 = 1
+	var sentToB int64
+	cluster := NewCluster([]string{"a", "b"})
+	cluster.MaxFailures = 2
+	cluster.ProbeInterval = 10 * time.Millisecond
+	sender := cluster.Sender(func(addr string) Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			if addr == "a" && atomic.LoadInt32(&down) == 1 {
+				return &PutError{StatusCode: http.StatusServiceUnavailable}
+			}
+			if addr == "b" {
+				atomic.AddInt64(&sentToB, 1)
+			}
+			return nil
+		})
+	})
+
+	// every batch is delivered, failed ones are retried on "b"
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, sender.Send(context.Background(), dps))
+	}
+	assert.EqualValues(t, 10, sentToB)
+	assert.Equal(t, []string{"b"}, cluster.Healthy())
+
+	// "a" is back and will be probed after ProbeInterval
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, sender.Send(context.Background(), dps))
+	assert.Equal(t, []string{"a", "b"}, cluster.Healthy())
+}
+
+func TestClusterAllDown(t *testing.T) {
+	var calls int64
+	cluster := NewCluster([]string{"a", "b"})
+	cluster.MaxFailures = 1
+	cluster.ProbeInterval = 20 * time.Millisecond
+	sender := cluster.Sender(func(addr string) Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			atomic.AddInt64(&calls, 1)
+			return &PutError{StatusCode: http.StatusServiceUnavailable}
+		})
+	})
+
+	assert.Error(t, sender.Send(context.Background(), dps))
+	assert.EqualValues(t, 2, calls)
+	assert.Empty(t, cluster.Healthy())
+
+	// endpoints are not tried until ProbeInterval passes
+	err := sender.Send(context.Background(), dps)
+	assert.Equal(t, ErrNoHealthyEndpoints, err)
+	assert.True(t, IsRetryable(err))
+	assert.EqualValues(t, 2, calls)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Error(t, sender.Send(context.Background(), dps))
+	assert.EqualValues(t, 4, calls)
+}
+
+func TestClusterNotRetryable(t *testing.T) {
+	var calls int64
+	cluster := NewCluster([]string{"a", "b"})
+	sender := cluster.Sender(func(addr string) Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			atomic.AddInt64(&calls, 1)
+			return &PutError{StatusCode: http.StatusBadRequest}
+		})
+	})
+
+	assert.Error(t, sender.Send(context.Background(), dps))
+	assert.EqualValues(t, 1, calls)
+	assert.Equal(t, []string{"a", "b"}, cluster.Healthy())
+}
+
+func TestClusterClient(t *testing.T) {
+	var metricsRecived int64
+	good, goodHost := createCountingServer(http.StatusNoContent, &metricsRecived, t)
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	badHost := strings.Replace(bad.URL, "http://", "", 1)
+
+	client, err := NewClusterClient([]string{goodHost, badHost}, 10, time.Second)
+	assert.NoError(t, err)
+	client.StartWorkers(2, 1, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.NoError(t, client.Close(context.Background()))
+	assert.EqualValues(t, 10, atomic.LoadInt64(&metricsRecived))
+	assert.EqualValues(t, 10, client.Sent)
+	assert.Equal(t, []string{"http://" + goodHost + "/api/put?details"}, client.Cluster.Healthy())
+}
+
+func TestClusterClientWithCredentials(t *testing.T) {
+	var received int64
+	createServer := func(user string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, _, _ := r.BasicAuth(); u != user {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			atomic.AddInt64(&received, 1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	first, second, third := createServer("first"), createServer("second"), createServer("")
+	defer first.Close()
+	defer second.Close()
+	defer third.Close()
+
+	client, err := NewClusterClient([]string{
+		strings.Replace(first.URL, "http://", "http://first:pass@", 1),
+		strings.Replace(second.URL, "http://", "http://second:pass@", 1),
+		strings.Replace(third.URL, "http://", "", 1),
+	}, 10, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, client.HTTP.Username)
+
+	qc, err := client.QueryClient()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", qc.config.Username)
+
+	client.StartWorkers(1, 1, time.Hour)
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.NoError(t, client.Close(context.Background()))
+	assert.EqualValues(t, 6, atomic.LoadInt64(&received))
+	assert.Len(t, client.Cluster.Healthy(), 3)
+}
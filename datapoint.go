@@ -5,10 +5,25 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
 
+// Precision is resolution of DataPoint timestamp
+type Precision int
+
+const (
+	// Seconds is for 10-digit unix timestamps
+	Seconds Precision = iota
+	// Milliseconds is for 13-digit unix timestamps
+	Milliseconds
+)
+
+// maxSeconds is the biggest timestamp in seconds, OpenTSDB treats timestamps
+// with more than 10 digits as milliseconds
+const maxSeconds = 9999999999
+
 // DataPoint is a data point for the /api/put route:
 // http://opentsdb.net/docs/build/html/api_http/put.html#example-single-data-point-put.
 type DataPoint struct {
@@ -18,21 +33,100 @@ type DataPoint struct {
 	Tags      Tags        `json:"tags"`
 }
 
+// NewDataPoint creates DataPoint with timestamp from t in given precision,
+// value should be created with Int, Float or ParseValue
+func NewDataPoint(metric string, t time.Time, precision Precision, value Value, tags Tags) *DataPoint {
+	return &DataPoint{
+		Metric:    metric,
+		Timestamp: Timestamp(t, precision),
+		Value:     value,
+		Tags:      tags,
+	}
+}
+
+// Timestamp returns unix timestamp of t in given precision
+func Timestamp(t time.Time, precision Precision) int64 {
+	if precision == Milliseconds {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return t.Unix()
+}
+
+// TimestampPrecision detects precision of ts by number of digits
+func TimestampPrecision(ts int64) Precision {
+	if ts > maxSeconds {
+		return Milliseconds
+	}
+	return Seconds
+}
+
+// NormalizeTimestamp converts ts, that could be in seconds or milliseconds,
+// to given precision
+func NormalizeTimestamp(ts int64, precision Precision) int64 {
+	switch from := TimestampPrecision(ts); {
+	case from == Milliseconds && precision == Seconds:
+		return ts / 1000
+	case from == Seconds && precision == Milliseconds:
+		return ts * 1000
+	}
+	return ts
+}
+
+// Precision returns precision of dp timestamp
+func (dp *DataPoint) Precision() Precision {
+	return TimestampPrecision(dp.Timestamp)
+}
+
+// Time returns dp timestamp as time.Time
+func (dp *DataPoint) Time() time.Time {
+	if dp.Precision() == Milliseconds {
+		return time.Unix(0, dp.Timestamp*int64(time.Millisecond))
+	}
+	return time.Unix(dp.Timestamp, 0)
+}
+
+// SetTime sets dp timestamp from t in given precision
+func (dp *DataPoint) SetTime(t time.Time, precision Precision) {
+	dp.Timestamp = Timestamp(t, precision)
+}
+
+// Normalize converts dp timestamp to given precision
+func (dp *DataPoint) Normalize(precision Precision) {
+	dp.Timestamp = NormalizeTimestamp(dp.Timestamp, precision)
+}
+
+// seconds returns dp timestamp in seconds regardless of its precision
+func (dp *DataPoint) seconds() int64 {
+	return NormalizeTimestamp(dp.Timestamp, Seconds)
+}
+
+// MarshalJSON encodes dp like encoding/json does, except that integer and
+// floating point values keep their type, e.g. float64(2) is encoded as 2.0
+func (dp DataPoint) MarshalJSON() ([]byte, error) {
+	var e jsonEncoder
+	if err := e.appendDataPoint(&dp); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
 // sys.cpu.user host=webserver01,cpu=1  1356998400  0
 // <metric> <timestamp> <value> <tagk=tagv> [<tagkN=tagvN>]
 func (dp DataPoint) String() string {
 	tags := strings.Trim(dp.Tags.String(), "{}")
-	return fmt.Sprintf("%s %d %3.6f %s",
-		dp.Metric, dp.Timestamp, dp.Value, strings.Replace(tags, ",", " ", -1))
+	return fmt.Sprintf("%s %d %s %s",
+		dp.Metric, dp.Timestamp, appendValue(nil, dp.Value), strings.Replace(tags, ",", " ", -1))
 }
 
 // DataPoints holds multiple DataPoints:
 // http://opentsdb.net/docs/build/html/api_http/put.html#example-multiple-data-point-put.
 type DataPoints []*DataPoint
 
-func (dps DataPoints) Len() int           { return len(dps) }
-func (dps DataPoints) Swap(i, j int)      { dps[i], dps[j] = dps[j], dps[i] }
-func (dps DataPoints) Less(i, j int) bool { return dps[i].Timestamp < dps[j].Timestamp }
+func (dps DataPoints) Len() int      { return len(dps) }
+func (dps DataPoints) Swap(i, j int) { dps[i], dps[j] = dps[j], dps[i] }
+func (dps DataPoints) Less(i, j int) bool {
+	return NormalizeTimestamp(dps[i].Timestamp, Milliseconds) < NormalizeTimestamp(dps[j].Timestamp, Milliseconds)
+}
 
 // Tags is a helper class for tags.
 type Tags map[string]string
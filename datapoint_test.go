@@ -1,7 +1,9 @@
 package opentsdb
 
 import (
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -36,6 +38,47 @@ func TestTagsToString(t *testing.T) {
 	assert.Equal(t, "{key1=val1,key2=val2,key3=val3,key4=val4,key5=val5}", tags.String())
 }
 
+func TestNewDataPointWithPrecision(t *testing.T) {
+	now := time.Unix(1356998400, 123456789)
+
+	dp := NewDataPoint("test1", now, Seconds, Int(1), Tags{"key": "val"})
+	assert.EqualValues(t, 1356998400, dp.Timestamp)
+	assert.Equal(t, Seconds, dp.Precision())
+	assert.Equal(t, time.Unix(1356998400, 0), dp.Time())
+
+	dp = NewDataPoint("test1", now, Milliseconds, Float(1.5), Tags{"key": "val"})
+	assert.EqualValues(t, 1356998400123, dp.Timestamp)
+	assert.Equal(t, Milliseconds, dp.Precision())
+	assert.Equal(t, time.Unix(1356998400, 123000000), dp.Time())
+	assert.Equal(t, "test1 1356998400123 1.5 key=val", dp.String())
+
+	dp.Normalize(Seconds)
+	assert.EqualValues(t, 1356998400, dp.Timestamp)
+	dp.Normalize(Milliseconds)
+	assert.EqualValues(t, 1356998400000, dp.Timestamp)
+}
+
+func TestNormalizeTimestamp(t *testing.T) {
+	assert.EqualValues(t, 1356998400, NormalizeTimestamp(1356998400, Seconds))
+	assert.EqualValues(t, 1356998400, NormalizeTimestamp(1356998400999, Seconds))
+	assert.EqualValues(t, 1356998400000, NormalizeTimestamp(1356998400, Milliseconds))
+	assert.EqualValues(t, 1356998400999, NormalizeTimestamp(1356998400999, Milliseconds))
+	assert.Equal(t, Seconds, TimestampPrecision(9999999999))
+	assert.Equal(t, Milliseconds, TimestampPrecision(10000000000))
+}
+
+func TestDataPointsSortWithMixedPrecision(t *testing.T) {
+	dps := DataPoints{
+		&DataPoint{"test1", 1356998401, 1, nil},
+		&DataPoint{"test1", 1356998400500, 1, nil},
+		&DataPoint{"test1", 1356998400, 1, nil},
+	}
+	sort.Sort(dps)
+	assert.EqualValues(t, 1356998400, dps[0].Timestamp)
+	assert.EqualValues(t, 1356998400500, dps[1].Timestamp)
+	assert.EqualValues(t, 1356998401, dps[2].Timestamp)
+}
+
 func BenchmarkTagsToString(b *testing.B) {
 	tags := make(Tags)
 	tags.Set("key5", "val5")
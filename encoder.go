@@ -0,0 +1,180 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// flushSize is how much of encoded batch is buffered before it's written out
+const flushSize = 32 * 1024
+
+// jsonEncoder encodes batches for /api/put without allocations. Its buffers
+// are reused between batches, so it's not safe for concurrent use. Output is
+// the same as from encoding/json, except for values, see DataPoint.MarshalJSON
+type jsonEncoder struct {
+	buf  []byte
+	keys []string
+}
+
+// encode writes batch to w as JSON array followed by newline and returns
+// number of written bytes
+func (e *jsonEncoder) encode(w io.Writer, batch DataPoints) (int, error) {
+	written := 0
+	e.buf = append(e.buf[:0], '[')
+	for i, dp := range batch {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := e.appendDataPoint(dp); err != nil {
+			return written, err
+		}
+		if len(e.buf) >= flushSize {
+			n, err := w.Write(e.buf)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			e.buf = e.buf[:0]
+		}
+	}
+	e.buf = append(e.buf, ']', '\n')
+	n, err := w.Write(e.buf)
+	return written + n, err
+}
+
+func (e *jsonEncoder) appendDataPoint(dp *DataPoint) error {
+	if dp == nil {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	var err error
+	e.buf = append(e.buf, `{"metric":`...)
+	e.buf = appendJSONString(e.buf, dp.Metric)
+	e.buf = append(e.buf, `,"timestamp":`...)
+	e.buf = appendInt(e.buf, dp.Timestamp)
+	e.buf = append(e.buf, `,"value":`...)
+	if e.buf, err = appendJSONValue(e.buf, dp.Value); err != nil {
+		return fmt.Errorf("failed to encode %s: %v", dp.Metric, err)
+	}
+	e.buf = append(e.buf, `,"tags":`...)
+	e.appendTags(dp.Tags)
+	e.buf = append(e.buf, '}')
+	return nil
+}
+
+// appendTags appends tags sorted by key, as encoding/json does
+func (e *jsonEncoder) appendTags(tags Tags) {
+	if tags == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.keys = e.keys[:0]
+	for key := range tags {
+		// insertion sort, there are only few tags
+		i := len(e.keys)
+		e.keys = append(e.keys, key)
+		for ; i > 0 && e.keys[i-1] > key; i-- {
+			e.keys[i] = e.keys[i-1]
+		}
+		e.keys[i] = key
+	}
+
+	e.buf = append(e.buf, '{')
+	for i, key := range e.keys {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = appendJSONString(e.buf, key)
+		e.buf = append(e.buf, ':')
+		e.buf = appendJSONString(e.buf, tags[key])
+	}
+	e.buf = append(e.buf, '}')
+}
+
+// appendJSONValue appends value of DataPoint as JSON, numbers are formatted
+// by appendValue, so integers and floats keep their type
+func appendJSONValue(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case string:
+		return appendJSONString(buf, v), nil
+	case json.Number:
+		return append(buf, v...), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return buf, fmt.Errorf("value %v is not finite", v)
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return buf, fmt.Errorf("value %v is not finite", v)
+		}
+	case Value:
+		if v.IsFloat() && (math.IsNaN(v.f) || math.IsInf(v.f, 0)) {
+			return buf, fmt.Errorf("value %v is not finite", v.f)
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+	default:
+		data, err := json.Marshal(value)
+		return append(buf, data...), err
+	}
+	return appendValue(buf, value), nil
+}
+
+func appendInt(buf []byte, i int64) []byte {
+	return Int(i).append(buf)
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString appends quoted s with the same escaping as encoding/json,
+// including HTML characters
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
@@ -0,0 +1,58 @@
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	batch := DataPoints{
+		&DataPoint{"test1", 123, 1, Tags{"key_z": "val1", "key_a": "val2"}},
+		&DataPoint{"test2", 1356998400123, 2.5, Tags{"b": "2", "a": "1", "c": "3"}},
+		&DataPoint{"test3", 123, Int(math.MaxInt64), nil},
+		&DataPoint{"test4", 123, "42", Tags{}},
+	}
+	var buf bytes.Buffer
+	var e jsonEncoder
+	n, err := e.encode(&buf, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Len(), n)
+	assert.Equal(t, `[{"metric":"test1","timestamp":123,"value":1,"tags":{"key_a":"val2","key_z":"val1"}},`+
+		`{"metric":"test2","timestamp":1356998400123,"value":2.5,"tags":{"a":"1","b":"2","c":"3"}},`+
+		`{"metric":"test3","timestamp":123,"value":9223372036854775807,"tags":null},`+
+		`{"metric":"test4","timestamp":123,"value":"42","tags":{}}]`+"\n", buf.String())
+
+	// encoder is reused for the next batch
+	buf.Reset()
+	_, err = e.encode(&buf, batch[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"metric":"test1","timestamp":123,"value":1,"tags":{"key_a":"val2","key_z":"val1"}}]`+"\n", buf.String())
+
+	_, err = e.encode(&buf, DataPoints{&DataPoint{"test1", 123, math.Inf(1), nil}})
+	assert.Error(t, err)
+}
+
+func TestJSONEncoderStrings(t *testing.T) {
+	for _, s := range []string{
+		"plain", `quo"te`, `back\slash`, "new\nline\r\t", "\x00\x1f", "<a&b>",
+		"юникод", "\u2028\u2029", "bad\xffutf8", "",
+	} {
+		expected, err := json.Marshal(s)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(appendJSONString(nil, s)), "%q", s)
+	}
+}
+
+func TestJSONEncoderAllocations(t *testing.T) {
+	batch := benchmarkBatch(100)
+	var e jsonEncoder
+	allocs := testing.AllocsPerRun(100, func() {
+		e.encode(ioutil.Discard, batch)
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,209 @@
+package opentsdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// PutError is returned by Postman when OpenTSDB responded to /api/put with
+// unexpected status. If response had OpenTSDB error JSON, it will be parsed
+// into Code, Message, Details and Trace:
+// http://opentsdb.net/docs/build/html/api_http/index.html#errors
+type PutError struct {
+	StatusCode int
+	Code       int
+	Message    string
+	Details    string
+	Trace      string
+	Body       string
+	BatchSize  int
+}
+
+func newPutError(statusCode int, body []byte, batchSize int) *PutError {
+	err := &PutError{StatusCode: statusCode, Body: string(body), BatchSize: batchSize}
+	err.Code, err.Message, err.Details, err.Trace = parseErrorBody(body)
+	return err
+}
+
+func (err *PutError) Error() string {
+	return fmt.Sprintf("unexpected status %d (%q)", err.StatusCode, err.Body)
+}
+
+// Is reports whether target is *PutError with the same StatusCode, so
+// errors.Is(err, &PutError{StatusCode: 413}) could be used
+func (err *PutError) Is(target error) bool {
+	t, ok := target.(*PutError)
+	return ok && t.StatusCode == err.StatusCode
+}
+
+// Retryable reports whether request could succeed if repeated: server errors,
+// timeouts and rate limiting are retryable, other client errors are not
+func (err *PutError) Retryable() bool {
+	return retryableStatus(err.StatusCode)
+}
+
+// APIError is returned by QueryClient when OpenTSDB responded to request
+// other than /api/put with unexpected status. Error JSON is parsed the same
+// way as for PutError
+type APIError struct {
+	StatusCode int
+	Code       int
+	Message    string
+	Details    string
+	Trace      string
+	Body       string
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	err := &APIError{StatusCode: statusCode, Body: string(body)}
+	err.Code, err.Message, err.Details, err.Trace = parseErrorBody(body)
+	return err
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("unexpected status %d (%q)", err.StatusCode, err.Body)
+}
+
+// Is reports whether target is *APIError with the same StatusCode, so
+// errors.Is(err, &APIError{StatusCode: 404}) could be used
+func (err *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.StatusCode == err.StatusCode
+}
+
+// Retryable reports whether request could succeed if repeated, the same way
+// as for PutError
+func (err *APIError) Retryable() bool {
+	return retryableStatus(err.StatusCode)
+}
+
+// parseErrorBody returns fields of OpenTSDB error JSON, if body has one
+func parseErrorBody(body []byte) (code int, message, details, trace string) {
+	var resp struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Details string `json:"details"`
+			Trace   string `json:"trace"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return 0, "", "", ""
+	}
+	return resp.Error.Code, resp.Error.Message, resp.Error.Details, resp.Error.Trace
+}
+
+// retryableStatus reports whether request that failed with given status
+// could succeed if repeated
+func retryableStatus(status int) bool {
+	switch {
+	case status >= 500:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// RejectedError is returned by Post when OpenTSDB refused to store some of
+// datapoints. Errors are only available if request was made with ?details
+type RejectedError struct {
+	PutResponse
+}
+
+func (err *RejectedError) Error() string {
+	msg := fmt.Sprintf("%d of %d datapoints rejected", err.Failed, err.Failed+err.Success)
+	if len(err.Errors) > 0 {
+		msg += fmt.Sprintf(", first: %q for %v", err.Errors[0].Error, err.Errors[0].DataPoint)
+	}
+	return msg
+}
+
+// Retryable is always false, OpenTSDB will reject the same datapoints again
+func (err *RejectedError) Retryable() bool {
+	return false
+}
+
+// SendError is returned by Client when batch was not delivered. Err is the
+// original error, Requeued is number of datapoints returned to Queue, Spooled
+// is number of datapoints written to Spool, and Attempts is number of
+// attempts made if client has Retry policy
+type SendError struct {
+	Err       error
+	BatchSize int
+	Requeued  int
+	Spooled   int
+	Attempts  int
+}
+
+func (err *SendError) Error() string {
+	var msg string
+	if err.Attempts > 0 {
+		msg = fmt.Sprintf("request failed: %v (gave up on %d datapoints after %d attempts",
+			err.Err, err.BatchSize, err.Attempts)
+	} else {
+		msg = fmt.Sprintf("request failed: %v (requeued %v/%v", err.Err, err.Requeued, err.BatchSize)
+	}
+	if err.Spooled > 0 {
+		msg += fmt.Sprintf(", spooled %d", err.Spooled)
+	}
+	return msg + ")"
+}
+
+// Lost returns number of datapoints that was neither requeued nor spooled
+func (err *SendError) Lost() int {
+	return err.BatchSize - err.Requeued - err.Spooled
+}
+
+func (err *SendError) Unwrap() error {
+	return err.Err
+}
+
+// Retryable reports whether original error is retryable
+func (err *SendError) Retryable() bool {
+	return IsRetryable(err.Err)
+}
+
+// IsRetryable reports whether request that failed with err could succeed if
+// repeated. Errors with Retryable() method returning true, timeouts, failures
+// to connect and broken connections are retryable. Cancellation, TLS and
+// other errors of misconfiguration, and unknown errors are not
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// every *url.Error is net.Error, so only what it wraps is checked
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,103 @@
+package opentsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutErrorWithOpenTSDBError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprint(w, `{"error":{"code":413,"message":"Chunked request not supported.",`+
+			`"details":"Request is too large","trace":"net.opentsdb..."}}`)
+	}))
+	defer ts.Close()
+
+	postman := NewPostman(time.Second)
+	err := postman.Post(dps, ts.URL)
+
+	var putErr *PutError
+	assert.True(t, errors.As(err, &putErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, putErr.StatusCode)
+	assert.Equal(t, 413, putErr.Code)
+	assert.Equal(t, "Chunked request not supported.", putErr.Message)
+	assert.Equal(t, "Request is too large", putErr.Details)
+	assert.Equal(t, "net.opentsdb...", putErr.Trace)
+	assert.Equal(t, 2, putErr.BatchSize)
+	assert.False(t, IsRetryable(err))
+	assert.True(t, errors.Is(err, &PutError{StatusCode: http.StatusRequestEntityTooLarge}))
+}
+
+func TestSendErrorUnwrap(t *testing.T) {
+	ts, host := createTestServerWith503()
+	defer ts.Close()
+
+	client, err := NewClient(host, 2, time.Second)
+	assert.NoError(t, err)
+
+	postman := NewPostman(time.Second)
+	err = client.Send(postman, dps)
+	var sendErr *SendError
+	assert.True(t, errors.As(err, &sendErr))
+	assert.Equal(t, 2, sendErr.BatchSize)
+	assert.Equal(t, 2, sendErr.Requeued)
+	assert.True(t, errors.Is(err, &PutError{StatusCode: http.StatusServiceUnavailable}))
+	assert.False(t, errors.Is(err, &PutError{StatusCode: http.StatusBadRequest}))
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("something"), false},
+		{&PutError{StatusCode: http.StatusBadRequest}, false},
+		{&PutError{StatusCode: http.StatusRequestEntityTooLarge}, false},
+		{&PutError{StatusCode: http.StatusTooManyRequests}, true},
+		{&PutError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&RejectedError{}, false},
+		{&SendError{Err: &PutError{StatusCode: http.StatusBadGateway}}, true},
+		{&APIError{StatusCode: http.StatusNotFound}, false},
+		{&APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.retryable, IsRetryable(c.err), "%v", c.err)
+	}
+
+	// network error
+	postman := NewPostman(time.Second)
+	err := postman.Post(dps, "http://127.0.0.1:1")
+	assert.True(t, IsRetryable(err))
+
+	// misconfiguration is not fixed by retries
+	err = postman.Post(dps, "ftp://127.0.0.1:1")
+	assert.False(t, IsRetryable(err))
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	err = postman.Post(dps, ts.URL)
+	assert.Error(t, err)
+	assert.False(t, IsRetryable(err), "%v", err)
+
+	// timeout
+	postman = NewPostmanWithConfig(&HTTPConfig{
+		Timeout:   10 * time.Millisecond,
+		TLSConfig: ts.Client().Transport.(*http.Transport).TLSClientConfig,
+	})
+	err = postman.Post(dps, ts.URL)
+	assert.Error(t, err)
+	assert.True(t, IsRetryable(err), "%v", err)
+}
@@ -0,0 +1,70 @@
+package opentsdb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientWithHTTPSAndBasicAuth(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+		assert.Equal(t, "/tsdb/api/put", r.URL.Path)
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "https://", "https://user:pass@", 1) + "/tsdb/"
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	client.HTTP.TLSConfig = &tls.Config{RootCAs: pool}
+	client.HTTP.Headers = http.Header{"X-Custom": []string{"value"}}
+	client.StartWorkers(1, 10, time.Hour)
+
+	assert.NoError(t, client.Push(dps[0]))
+	assert.NoError(t, client.Close(context.Background()))
+	assert.EqualValues(t, 1, client.Sent)
+}
+
+func TestPostmanWithBearerToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	postman := NewPostmanWithConfig(&HTTPConfig{Timeout: time.Second, BearerToken: "secret"})
+	assert.NoError(t, postman.Post(dps, ts.URL))
+}
+
+func TestParseHost(t *testing.T) {
+	base, err := parseHost("localhost:4242")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:4242/api/put?details", endpointURL(base, "api/put", "details"))
+
+	base, err = parseHost("https://localhost/prefix/")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://localhost/prefix/api/put", endpointURL(base, "api/put", ""))
+
+	_, err = parseHost("http://***111")
+	assert.Error(t, err)
+}
+
+func TestLoadTLSConfigWithMissingFile(t *testing.T) {
+	_, err := LoadTLSConfig("/nonexistent/ca.pem", "", "")
+	assert.Error(t, err)
+}
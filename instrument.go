@@ -0,0 +1,86 @@
+package opentsdb
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInstrumentPrefix is prefix of client own metrics
+const DefaultInstrumentPrefix = "opentsdb.client."
+
+// Instrument starts Reporter, that pushes client own metrics every interval
+// through the client itself: counters sent, dropped, rejected, failed,
+// retried, spooled, invalid, splits, bytes.raw and bytes.wire, gauge
+// queue.depth, and histograms batch.size and send.latency (in ms) of batches
+// sent since the previous report. Metric names are prefixed with prefix, or
+// DefaultInstrumentPrefix if it's empty, and have given tags, or host tag if
+// there are none, as OpenTSDB requires at least one. Reporter makes final
+// report on Close. Client is instrumented only once, later calls return the
+// same Reporter and ignore their arguments
+func (client *Client) Instrument(prefix string, tags Tags, interval time.Duration) *Reporter {
+	client.mu.Lock()
+	if client.reporter != nil {
+		client.mu.Unlock()
+		return client.reporter
+	}
+
+	if prefix == "" {
+		prefix = DefaultInstrumentPrefix
+	}
+	if len(tags) == 0 {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		tags = Tags{}
+		tags.Set("host", host)
+	}
+
+	// distributions are collected only from now on, so uninstrumented
+	// client doesn't pay for them
+	client.batchSizes = NewHistogram("batch.size", nil)
+	client.latency = NewHistogram("send.latency", nil)
+	atomic.StoreInt32(&client.instrumented, 1)
+
+	registry := NewRegistry(prefix, tags)
+	registry.Register("client", nil, &clientMetrics{client})
+	reporter := NewReporter(client, registry, interval)
+	client.reporter = reporter
+	client.mu.Unlock()
+
+	reporter.Start()
+	return reporter
+}
+
+// clientMetrics is Metric of client own Stats and distributions
+type clientMetrics struct {
+	client *Client
+}
+
+func (m *clientMetrics) Collect(timestamp int64) DataPoints {
+	stats := m.client.Stats()
+	counters := []struct {
+		metric string
+		value  int64
+	}{
+		{"sent", stats.Sent},
+		{"dropped", stats.Dropped},
+		{"rejected", stats.Rejected},
+		{"failed", stats.Failed},
+		{"retried", stats.Retried},
+		{"spooled", stats.Spooled},
+		{"invalid", stats.Invalid},
+		{"splits", stats.Splits},
+		{"bytes.raw", stats.RawBytes},
+		{"bytes.wire", stats.WireBytes},
+		{"queue.depth", int64(stats.Queued)},
+	}
+
+	dps := make(DataPoints, 0, len(counters)+16)
+	for _, c := range counters {
+		dps = append(dps, &DataPoint{c.metric, timestamp, Int(c.value), nil})
+	}
+	dps = append(dps, m.client.batchSizes.Collect(timestamp)...)
+	return append(dps, m.client.latency.Collect(timestamp)...)
+}
@@ -0,0 +1,60 @@
+package opentsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	sender := &recorder{}
+	client := NewClientWithSenders(100, func() Sender { return sender })
+	client.StartWorkers(1, 100, time.Hour)
+
+	// batches are not observed until client is instrumented
+	assert.NoError(t, client.Push(&DataPoint{"test1", 122, 1, Tags{"key": "val"}}))
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.Nil(t, client.batchSizes)
+
+	reporter := client.Instrument("myapp.tsdb.", Tags{"host": "web01"}, time.Hour)
+	assert.Equal(t, reporter, client.Instrument("other.", nil, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.NoError(t, client.Close(context.Background()))
+
+	values := map[string]Value{}
+	for _, batch := range sender.batches {
+		for _, dp := range batch {
+			if dp.Metric == "test1" {
+				continue
+			}
+			assert.Equal(t, Tags{"host": "web01"}, dp.Tags)
+			assert.NoError(t, dp.Validate())
+			values[dp.Metric] = dp.Value.(Value)
+		}
+	}
+	assert.Equal(t, Int(4), values["myapp.tsdb.sent"])
+	assert.Equal(t, Int(0), values["myapp.tsdb.dropped"])
+	assert.Equal(t, Int(0), values["myapp.tsdb.queue.depth"])
+	assert.Equal(t, Int(1), values["myapp.tsdb.batch.size.count"])
+	assert.Equal(t, Float(3), values["myapp.tsdb.batch.size.max"])
+	assert.Equal(t, Int(1), values["myapp.tsdb.send.latency.count"])
+	assert.Contains(t, values, "myapp.tsdb.send.latency.p99")
+	assert.Contains(t, values, "myapp.tsdb.bytes.wire")
+}
+
+func TestInstrumentDefaults(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	reporter := client.Instrument("", nil, time.Hour)
+	assert.NoError(t, reporter.Report())
+
+	dp := <-client.Queue
+	assert.Equal(t, "opentsdb.client.sent", dp.Metric)
+	assert.Contains(t, dp.Tags, "host")
+	assert.NoError(t, dp.Validate())
+}
@@ -1,7 +1,9 @@
 package opentsdb
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -87,7 +89,7 @@ func TestIntegrationWithFailedSend(t *testing.T) {
 
 		// Will fail after 20 successful POSTs
 		if metricsRecived > 20 {
-			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			atomic.AddInt64(&metricsRecived, int64(len(data)))
 			w.WriteHeader(http.StatusNoContent)
@@ -126,7 +128,7 @@ func TestIntegrationWithFailedSend(t *testing.T) {
 	case err = <-client.Errors:
 	default:
 	}
-	assert.EqualError(t, err, "request failed: unexpected status 404 (\"\") (requeued 2/2)")
+	assert.EqualError(t, err, "request failed: unexpected status 503 (\"\") (requeued 2/2)")
 
 	assert.EqualValues(t, 22, client.Sent)
 	assert.EqualValues(t, 22, metricsRecived)
@@ -157,6 +159,46 @@ func BenchmarkWorkers8_10(b *testing.B) {
 	runBenchmark(b, 8, 10)
 }
 
+func BenchmarkEncodeBatch(b *testing.B) {
+	batch := benchmarkBatch(100)
+	var e jsonEncoder
+	writer := gzip.NewWriter(ioutil.Discard)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Reset(ioutil.Discard)
+		if _, err := e.encode(writer, batch); err != nil {
+			b.Fatal(err)
+		}
+		writer.Close()
+	}
+}
+
+func BenchmarkEncodeBatchStdlib(b *testing.B) {
+	batch := benchmarkBatch(100)
+	writer := gzip.NewWriter(ioutil.Discard)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Reset(ioutil.Discard)
+		if err := json.NewEncoder(writer).Encode(batch); err != nil {
+			b.Fatal(err)
+		}
+		writer.Close()
+	}
+}
+
+func benchmarkBatch(size int) DataPoints {
+	batch := make(DataPoints, 0, size)
+	for i := 0; i < size; i++ {
+		batch = append(batch, &DataPoint{"sys.cpu.user", 1356998400 + int64(i), float64(i) / 3,
+			Tags{"host": "web01", "cpu": "0", "dc": "eu-west", "env": "production"}})
+	}
+	return batch
+}
+
 func runBenchmark(b *testing.B, workers, batchSize int) {
 	var metricsRecived int64
 
@@ -0,0 +1,74 @@
+package opentsdb
+
+import (
+	"context"
+	"fmt"
+)
+
+// LastQuery is body of /api/query/last request:
+// http://opentsdb.net/docs/build/html/api_http/query/last.html
+type LastQuery struct {
+	Queries []*LastSubQuery `json:"queries"`
+	// ResolveNames returns metric and tag names instead of just TSUIDs
+	ResolveNames bool `json:"resolveNames"`
+	// BackScan is number of hours to search back for the last value, if
+	// there is no meta data for series
+	BackScan int `json:"backScan,omitempty"`
+}
+
+// LastSubQuery is either Metric with Tags or list of TSUIDs
+type LastSubQuery struct {
+	Metric string   `json:"metric,omitempty"`
+	Tags   Tags     `json:"tags,omitempty"`
+	TSUIDs []string `json:"tsuids,omitempty"`
+}
+
+// LastDataPoint is result of /api/query/last. DataPoint has Value parsed
+// into Value and millisecond Timestamp, Metric and Tags are empty unless
+// ResolveNames was set
+type LastDataPoint struct {
+	DataPoint *DataPoint
+	TSUID     string
+}
+
+// QueryLast returns the last datapoints of series from q
+func (qc *QueryClient) QueryLast(ctx context.Context, q *LastQuery) ([]*LastDataPoint, error) {
+	var resp []struct {
+		Metric    string      `json:"metric"`
+		Timestamp int64       `json:"timestamp"`
+		Value     interface{} `json:"value"`
+		Tags      Tags        `json:"tags"`
+		TSUID     string      `json:"tsuid"`
+	}
+	if err := qc.do(ctx, "POST", "api/query/last", nil, q, &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]*LastDataPoint, 0, len(resp))
+	for _, r := range resp {
+		// value is sent as string
+		value, err := ParseValue(r.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last value of %s: %v", r.TSUID, err)
+		}
+		result = append(result, &LastDataPoint{
+			DataPoint: &DataPoint{r.Metric, r.Timestamp, value, r.Tags},
+			TSUID:     r.TSUID,
+		})
+	}
+	return result, nil
+}
+
+// Last returns the last datapoint of series with given metric and tags, or
+// nil if there is none
+func (qc *QueryClient) Last(ctx context.Context, metric string, tags Tags, backScan int) (*DataPoint, error) {
+	result, err := qc.QueryLast(ctx, &LastQuery{
+		Queries:      []*LastSubQuery{{Metric: metric, Tags: tags}},
+		ResolveNames: true,
+		BackScan:     backScan,
+	})
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0].DataPoint, nil
+}
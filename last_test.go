@@ -0,0 +1,60 @@
+package opentsdb
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/query/last", r.URL.Path)
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"queries":[{"metric":"sys.cpu.user","tags":{"host":"web01"}},`+
+			`{"tsuids":["000001000001000001"]}],"resolveNames":false,"backScan":24}`, string(body))
+
+		fmt.Fprint(w, `[{"timestamp":1356998400500,"value":"1.5","tsuid":"000001000001000002"},`+
+			`{"timestamp":1356998400000,"value":"42","tsuid":"000001000001000001"}]`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	result, err := qc.QueryLast(context.Background(), &LastQuery{
+		Queries: []*LastSubQuery{
+			{Metric: "sys.cpu.user", Tags: Tags{"host": "web01"}},
+			{TSUIDs: []string{"000001000001000001"}},
+		},
+		BackScan: 24,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "000001000001000002", result[0].TSUID)
+	assert.Equal(t, &DataPoint{"", 1356998400500, Float(1.5), nil}, result[0].DataPoint)
+	assert.Equal(t, Int(42), result[1].DataPoint.Value)
+}
+
+func TestLast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), `"resolveNames":true`)
+		fmt.Fprint(w, `[{"metric":"sys.cpu.user","timestamp":1356998400000,"value":"2",`+
+			`"tags":{"host":"web01"},"tsuid":"000001000001000001"}]`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	dp, err := qc.Last(context.Background(), "sys.cpu.user", Tags{"host": "web01"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &DataPoint{"sys.cpu.user", 1356998400000, Int(2), Tags{"host": "web01"}}, dp)
+	assert.Equal(t, "sys.cpu.user 1356998400000 2 host=web01", dp.String())
+}
@@ -0,0 +1,380 @@
+package opentsdb
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metric is anything that could be turned into datapoints on flush, like
+// Counter, Gauge or Histogram
+type Metric interface {
+	// Collect returns current state of metric as datapoints with given
+	// timestamp, metric may reset itself after that
+	Collect(timestamp int64) DataPoints
+}
+
+// CounterMode defines what Counter reports on flush
+type CounterMode int
+
+const (
+	// Cumulative reports total since counter was created
+	Cumulative CounterMode = iota
+	// Delta reports increase since previous flush
+	Delta
+)
+
+// Counter is monotonic integer counter, it's safe for concurrent use
+type Counter struct {
+	Metric string
+	Tags   Tags
+	Mode   CounterMode
+
+	value int64
+}
+
+// NewCounter creates Counter with given mode
+func NewCounter(metric string, tags Tags, mode CounterMode) *Counter {
+	return &Counter{Metric: metric, Tags: tags, Mode: mode}
+}
+
+// Add adds n to counter
+func (counter *Counter) Add(n int64) {
+	atomic.AddInt64(&counter.value, n)
+}
+
+// Inc adds one to counter
+func (counter *Counter) Inc() {
+	counter.Add(1)
+}
+
+// Value returns current value of counter, that is since last flush for Delta
+func (counter *Counter) Value() int64 {
+	return atomic.LoadInt64(&counter.value)
+}
+
+// Collect implements Metric, Delta counter is reset to zero
+func (counter *Counter) Collect(timestamp int64) DataPoints {
+	var value int64
+	if counter.Mode == Delta {
+		value = atomic.SwapInt64(&counter.value, 0)
+	} else {
+		value = atomic.LoadInt64(&counter.value)
+	}
+	return DataPoints{&DataPoint{counter.Metric, timestamp, Int(value), counter.Tags}}
+}
+
+// Gauge holds last value that was set, it's safe for concurrent use
+type Gauge struct {
+	Metric string
+	Tags   Tags
+
+	bits uint64
+	set  int32
+}
+
+// NewGauge creates Gauge, it's not reported until value is set
+func NewGauge(metric string, tags Tags) *Gauge {
+	return &Gauge{Metric: metric, Tags: tags}
+}
+
+// Set sets current value of gauge
+func (gauge *Gauge) Set(value float64) {
+	atomic.StoreUint64(&gauge.bits, math.Float64bits(value))
+	atomic.StoreInt32(&gauge.set, 1)
+}
+
+// Value returns last value of gauge
+func (gauge *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&gauge.bits))
+}
+
+// Collect implements Metric
+func (gauge *Gauge) Collect(timestamp int64) DataPoints {
+	if atomic.LoadInt32(&gauge.set) == 0 {
+		return nil
+	}
+	return DataPoints{&DataPoint{gauge.Metric, timestamp, Float(gauge.Value()), gauge.Tags}}
+}
+
+// DefaultPercentiles are reported by Histogram if none was given
+var DefaultPercentiles = []float64{0.5, 0.95, 0.99}
+
+// DefaultSamples is size of Histogram reservoir
+const DefaultSamples = 1024
+
+// Histogram collects distribution of values between flushes and reports it
+// as <metric>.count, .sum, .min, .max and .pNN for every percentile, e.g.
+// .p50 and .p99.9. Count, sum, min and max are exact, while percentiles are
+// estimated from uniform sample of at most Samples values. Histogram is reset
+// on every flush and is safe for concurrent use
+type Histogram struct {
+	Metric      string
+	Tags        Tags
+	Percentiles []float64
+	Samples     int
+
+	mu       sync.Mutex
+	count    int64
+	sum      float64
+	min, max float64
+	values   []float64
+}
+
+// NewHistogram creates Histogram that reports given percentiles, from 0 to 1,
+// or DefaultPercentiles
+func NewHistogram(metric string, tags Tags, percentiles ...float64) *Histogram {
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+	return &Histogram{
+		Metric:      metric,
+		Tags:        tags,
+		Percentiles: percentiles,
+		Samples:     DefaultSamples,
+	}
+}
+
+// Observe adds value to histogram
+func (histogram *Histogram) Observe(value float64) {
+	histogram.mu.Lock()
+	defer histogram.mu.Unlock()
+
+	histogram.count++
+	histogram.sum += value
+	if histogram.count == 1 || value < histogram.min {
+		histogram.min = value
+	}
+	if histogram.count == 1 || value > histogram.max {
+		histogram.max = value
+	}
+
+	// reservoir sampling, every value has equal chance to be in sample
+	if len(histogram.values) < histogram.Samples {
+		histogram.values = append(histogram.values, value)
+	} else if i := rand.Int63n(histogram.count); i < int64(len(histogram.values)) {
+		histogram.values[i] = value
+	}
+}
+
+// ObserveDuration adds duration since start in milliseconds
+func (histogram *Histogram) ObserveDuration(start time.Time) {
+	histogram.Observe(float64(time.Since(start)) / float64(time.Millisecond))
+}
+
+// Collect implements Metric, only count is reported if there was no values
+// since previous flush
+func (histogram *Histogram) Collect(timestamp int64) DataPoints {
+	histogram.mu.Lock()
+	count, sum, min, max := histogram.count, histogram.sum, histogram.min, histogram.max
+	sorted := append([]float64(nil), histogram.values...)
+	histogram.count, histogram.sum, histogram.min, histogram.max = 0, 0, 0, 0
+	histogram.values = histogram.values[:0]
+	histogram.mu.Unlock()
+
+	dp := func(suffix string, value Value) *DataPoint {
+		return &DataPoint{histogram.Metric + "." + suffix, timestamp, value, histogram.Tags}
+	}
+	dps := DataPoints{dp("count", Int(count))}
+	if count == 0 {
+		return dps
+	}
+	dps = append(dps, dp("sum", Float(sum)), dp("min", Float(min)), dp("max", Float(max)))
+
+	sort.Float64s(sorted)
+	for _, q := range histogram.Percentiles {
+		dps = append(dps, dp(percentileName(q), Float(percentile(sorted, q))))
+	}
+	return dps
+}
+
+// percentile returns q-th percentile of sorted values with nearest-rank method
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// percentileName returns suffix for percentile, e.g. p99 for 0.99
+func percentileName(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+}
+
+// Scheduler keeps metrics keyed by metric name and tags and pushes them to
+// Client every interval with timestamps aligned to interval. Metrics are
+// created on first use, so it's safe to call Counter, Gauge or Histogram
+// every time value changes
+type Scheduler struct {
+	client   *Client
+	interval time.Duration
+
+	mu       sync.Mutex
+	metrics  map[string]Metric
+	keys     []string
+	reporter *Reporter
+}
+
+// DefaultFlushInterval is used by Scheduler if interval is not positive
+const DefaultFlushInterval = 10 * time.Second
+
+// NewScheduler creates Scheduler for client, Start should be called to begin
+// periodic flushes. DefaultFlushInterval is used if interval is not positive
+func NewScheduler(client *Client, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	return &Scheduler{
+		client:   client,
+		interval: interval,
+		metrics:  make(map[string]Metric),
+	}
+}
+
+// Counter returns Counter for metric and tags, creating it with given mode
+func (scheduler *Scheduler) Counter(metric string, tags Tags, mode CounterMode) *Counter {
+	m := scheduler.metric("counter", metric, tags, func() Metric { return NewCounter(metric, tags, mode) })
+	return m.(*Counter)
+}
+
+// Gauge returns Gauge for metric and tags
+func (scheduler *Scheduler) Gauge(metric string, tags Tags) *Gauge {
+	m := scheduler.metric("gauge", metric, tags, func() Metric { return NewGauge(metric, tags) })
+	return m.(*Gauge)
+}
+
+// Histogram returns Histogram for metric and tags, creating it with given
+// percentiles
+func (scheduler *Scheduler) Histogram(metric string, tags Tags, percentiles ...float64) *Histogram {
+	m := scheduler.metric("histogram", metric, tags, func() Metric { return NewHistogram(metric, tags, percentiles...) })
+	return m.(*Histogram)
+}
+
+// metric returns existing metric of given kind or creates new one
+func (scheduler *Scheduler) metric(kind, metric string, tags Tags, create func() Metric) Metric {
+	key := kind + ":" + metric + tags.String()
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	if m, ok := scheduler.metrics[key]; ok {
+		return m
+	}
+	m := create()
+	scheduler.metrics[key] = m
+	scheduler.keys = append(scheduler.keys, key)
+	return m
+}
+
+// Collect implements Metric for all metrics of scheduler
+func (scheduler *Scheduler) Collect(timestamp int64) DataPoints {
+	scheduler.mu.Lock()
+	metrics := make([]Metric, 0, len(scheduler.keys))
+	for _, key := range scheduler.keys {
+		metrics = append(metrics, scheduler.metrics[key])
+	}
+	scheduler.mu.Unlock()
+
+	var dps DataPoints
+	for _, m := range metrics {
+		dps = append(dps, m.Collect(timestamp)...)
+	}
+	return dps
+}
+
+// Start begins flushing metrics every interval until Stop or client Close
+func (scheduler *Scheduler) Start() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if scheduler.reporter == nil {
+		scheduler.reporter = NewReporter(scheduler.client, scheduler, scheduler.interval)
+		scheduler.reporter.Start()
+	}
+}
+
+// Stop stops periodic flushes and flushes metrics for the last time
+func (scheduler *Scheduler) Stop() error {
+	scheduler.mu.Lock()
+	reporter := scheduler.reporter
+	scheduler.reporter = nil
+	scheduler.mu.Unlock()
+
+	if reporter != nil {
+		return reporter.Stop()
+	}
+	return scheduler.Flush()
+}
+
+// Flush pushes current state of all metrics to client with timestamp of the
+// current interval
+func (scheduler *Scheduler) Flush() error {
+	return scheduler.flush(time.Now())
+}
+
+func (scheduler *Scheduler) flush(now time.Time) error {
+	return pushAll(scheduler.client, scheduler.Collect(alignedTimestamp(now, scheduler.interval)))
+}
+
+// pushAll pushes dps to client and returns last error
+func pushAll(client *Client, dps DataPoints) error {
+	var err error
+	for _, dp := range dps {
+		if e := client.Push(dp); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// alignedTimestamp returns start of interval that t belongs to, in seconds,
+// or in milliseconds if interval is not whole number of seconds
+func alignedTimestamp(t time.Time, interval time.Duration) int64 {
+	if interval <= 0 {
+		return Timestamp(t, Seconds)
+	}
+	t = t.Truncate(interval)
+	if interval%time.Second != 0 {
+		return Timestamp(t, Milliseconds)
+	}
+	return Timestamp(t, Seconds)
+}
+
+// schedule calls flush at every interval boundary until stop is closed,
+// interval should be positive
+func schedule(interval time.Duration, stop <-chan struct{}, done chan<- struct{}, flush func(time.Time)) {
+	defer close(done)
+	if interval <= 0 {
+		<-stop
+		return
+	}
+	var prev time.Time
+	for {
+		// boundary is fixed before timer is armed, so late timer doesn't
+		// shift flushed interval, nor early one repeats it
+		now := time.Now()
+		next := now.Truncate(interval).Add(interval)
+		if !next.After(prev) {
+			next = prev.Add(interval)
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+			// flush interval that has just ended
+			flush(next.Add(-interval))
+			prev = next
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package opentsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	tags := Tags{"host": "web01"}
+	cumulative := NewCounter("requests", tags, Cumulative)
+	delta := NewCounter("requests", tags, Delta)
+	for i := 0; i < 3; i++ {
+		cumulative.Inc()
+		delta.Add(2)
+	}
+
+	assert.Equal(t, DataPoints{{"requests", 123, Int(3), tags}}, cumulative.Collect(123))
+	assert.Equal(t, DataPoints{{"requests", 123, Int(6), tags}}, delta.Collect(123))
+
+	cumulative.Inc()
+	assert.Equal(t, DataPoints{{"requests", 124, Int(4), tags}}, cumulative.Collect(124))
+	assert.Equal(t, DataPoints{{"requests", 124, Int(0), tags}}, delta.Collect(124))
+}
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge("memory", Tags{"host": "web01"})
+	assert.Nil(t, gauge.Collect(123))
+
+	gauge.Set(1.5)
+	gauge.Set(2)
+	assert.Equal(t, DataPoints{{"memory", 123, Float(2), Tags{"host": "web01"}}}, gauge.Collect(123))
+	// last value is kept
+	assert.Len(t, gauge.Collect(124), 1)
+}
+
+func TestHistogram(t *testing.T) {
+	tags := Tags{"host": "web01"}
+	histogram := NewHistogram("latency", tags, 0.5, 0.9, 0.999)
+	for i := 100; i > 0; i-- {
+		histogram.Observe(float64(i))
+	}
+
+	values := map[string]Value{}
+	for _, dp := range histogram.Collect(123) {
+		assert.EqualValues(t, 123, dp.Timestamp)
+		assert.Equal(t, tags, dp.Tags)
+		values[dp.Metric] = dp.Value.(Value)
+	}
+	assert.Equal(t, map[string]Value{
+		"latency.count": Int(100),
+		"latency.sum":   Float(5050),
+		"latency.min":   Float(1),
+		"latency.max":   Float(100),
+		"latency.p50":   Float(50),
+		"latency.p90":   Float(90),
+		"latency.p99.9": Float(100),
+	}, values)
+
+	// histogram is reset on flush
+	assert.Equal(t, DataPoints{{"latency.count", 124, Int(0), tags}}, histogram.Collect(124))
+}
+
+func TestHistogramSampling(t *testing.T) {
+	histogram := NewHistogram("latency", nil)
+	histogram.Samples = 10
+	for i := 0; i < 1000; i++ {
+		histogram.Observe(float64(i))
+	}
+	assert.Len(t, histogram.values, 10)
+
+	dps := histogram.Collect(123)
+	assert.Equal(t, Int(1000), dps[0].Value)
+	assert.Equal(t, Float(999), dps[3].Value)
+}
+
+func TestScheduler(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	scheduler := NewScheduler(client, time.Minute)
+
+	tags := Tags{"host": "web01"}
+	scheduler.Counter("requests", tags, Delta).Inc()
+	scheduler.Counter("requests", Tags{"host": "web01"}, Delta).Inc()
+	scheduler.Counter("requests", Tags{"host": "web02"}, Delta).Inc()
+	scheduler.Gauge("requests", tags).Set(5)
+
+	now := time.Unix(1356998430, 0)
+	assert.NoError(t, scheduler.flush(now))
+	assert.Len(t, client.Queue, 3)
+
+	dp := <-client.Queue
+	assert.Equal(t, &DataPoint{"requests", 1356998400, Int(2), tags}, dp)
+	dp = <-client.Queue
+	assert.Equal(t, &DataPoint{"requests", 1356998400, Int(1), Tags{"host": "web02"}}, dp)
+	dp = <-client.Queue
+	assert.Equal(t, &DataPoint{"requests", 1356998400, Float(5), tags}, dp)
+}
+
+func TestSchedulerStartStop(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	scheduler := NewScheduler(client, 20*time.Millisecond)
+	scheduler.Counter("requests", Tags{"host": "web01"}, Cumulative).Inc()
+
+	scheduler.Start()
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, scheduler.Stop())
+
+	n := len(client.Queue)
+	assert.True(t, n >= 2, "%d flushes", n)
+	var prev int64
+	for i := 0; i < n; i++ {
+		dp := <-client.Queue
+		assert.Equal(t, Milliseconds, dp.Precision())
+		assert.Zero(t, dp.Timestamp%20)
+		assert.True(t, dp.Timestamp >= prev)
+		prev = dp.Timestamp
+	}
+}
+
+func TestSchedulerWithZeroInterval(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	scheduler := NewScheduler(client, 0)
+	assert.Equal(t, DefaultFlushInterval, scheduler.interval)
+	scheduler.Counter("requests", nil, Cumulative).Inc()
+
+	scheduler.Start()
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, client.Queue, 0)
+	assert.NoError(t, scheduler.Stop())
+	assert.Len(t, client.Queue, 1)
+}
+
+func TestSchedule(t *testing.T) {
+	interval := 20 * time.Millisecond
+	stop, done := make(chan struct{}), make(chan struct{})
+	var flushed []time.Time
+	go schedule(interval, stop, done, func(t time.Time) {
+		flushed = append(flushed, t)
+		// slow flush makes next timer late, but not next interval
+		time.Sleep(interval / 2)
+	})
+	time.Sleep(5 * interval)
+	close(stop)
+	<-done
+
+	assert.True(t, len(flushed) >= 3)
+	for i, ts := range flushed {
+		assert.Equal(t, ts.Truncate(interval), ts)
+		assert.True(t, ts.Add(interval).Before(time.Now()))
+		if i > 0 {
+			assert.True(t, ts.After(flushed[i-1]))
+		}
+	}
+}
+
+func TestAlignedTimestamp(t *testing.T) {
+	now := time.Unix(1356998459, 999000000)
+	assert.EqualValues(t, 1356998400, alignedTimestamp(now, time.Minute))
+	assert.EqualValues(t, 1356998459, alignedTimestamp(now, time.Second))
+	assert.EqualValues(t, 1356998459500, alignedTimestamp(now, 500*time.Millisecond))
+}
@@ -1,10 +1,14 @@
 package opentsdb
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,7 +46,7 @@ func TestNewClientWithInvalidHost(t *testing.T) {
 }
 
 func TestClientSendWithErrorAndFailedToRequeue(t *testing.T) {
-	ts, host := createTestServerWith404()
+	ts, host := createTestServerWith503()
 	defer ts.Close()
 
 	// bufferSize is less than len(dps) == 2
@@ -54,12 +58,12 @@ func TestClientSendWithErrorAndFailedToRequeue(t *testing.T) {
 	err = client.Send(postman, dps)
 	assert.Error(t, err)
 
-	expected := `request failed: unexpected status 404 ("Nothing here, move along") (requeued 1/2)`
+	expected := `request failed: unexpected status 503 ("Try again later") (requeued 1/2)`
 	assert.EqualError(t, err, expected)
 }
 
 func TestClientSendWithErrorAndAllRequeued(t *testing.T) {
-	ts, host := createTestServerWith404()
+	ts, host := createTestServerWith503()
 	defer ts.Close()
 
 	// bufferSize is equal to len(dps) == 2
@@ -71,10 +75,87 @@ func TestClientSendWithErrorAndAllRequeued(t *testing.T) {
 	err = client.Send(postman, dps)
 	assert.Error(t, err)
 
-	expected := `request failed: unexpected status 404 ("Nothing here, move along") (requeued 2/2)`
+	expected := `request failed: unexpected status 503 ("Try again later") (requeued 2/2)`
 	assert.EqualError(t, err, expected)
 }
 
+func TestClientSendWithErrorThatIsNotRetryable(t *testing.T) {
+	ts, host := createTestServerWith404()
+	defer ts.Close()
+
+	client, err := NewClient(host, 2, 5*time.Second)
+	assert.NoError(t, err)
+
+	postman := NewPostman(time.Second)
+	err = client.Send(postman, dps)
+	expected := `request failed: unexpected status 404 ("Nothing here, move along") (requeued 0/2)`
+	assert.EqualError(t, err, expected)
+	assert.EqualValues(t, 2, client.Failed)
+	assert.Len(t, client.Queue, 0)
+
+	// batch that can't be encoded is not requeued either
+	err = client.Send(postman, DataPoints{&DataPoint{"test1", 123, math.NaN(), Tags{"key": "val"}}})
+	assert.Error(t, err)
+	assert.EqualValues(t, 3, client.Failed)
+	assert.Len(t, client.Queue, 0)
+}
+
+func TestClientSendWithRejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"success":1,"failed":1,"errors":[{"datapoint":`+
+			`{"metric":"test2","timestamp":234,"value":2,"tags":{"type":"test"}},"error":"bad"}]}`)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "http://", "", 1)
+
+	client, err := NewClient(host, 2, 5*time.Second)
+	assert.NoError(t, err)
+
+	postman := NewPostman(time.Second)
+	err = client.Send(postman, dps)
+	assert.IsType(t, &RejectedError{}, err)
+	assert.EqualValues(t, 1, client.Sent)
+	assert.EqualValues(t, 1, client.Rejected)
+	assert.Len(t, client.Queue, 0)
+}
+
+func TestClientSendSplitsTooLargeBatch(t *testing.T) {
+	var requests, received int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		body, err := gzipBodyReader(r.Body)
+		assert.NoError(t, err)
+		data := []interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(body), &data))
+		if len(data) > 2 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		atomic.AddInt64(&received, int64(len(data)))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "http://", "", 1)
+
+	client, err := NewClient(host, 10, 5*time.Second)
+	assert.NoError(t, err)
+
+	batch := DataPoints{}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, &DataPoint{"test1", 123, i, Tags{"key": "val"}})
+	}
+	postman := NewPostman(time.Second)
+	assert.NoError(t, client.Send(postman, batch))
+
+	// 5 -> 2 + 3 -> 2 + (1 + 2)
+	assert.EqualValues(t, 5, requests)
+	assert.EqualValues(t, 5, received)
+	assert.EqualValues(t, 5, client.Sent)
+	assert.EqualValues(t, 2, client.Splits)
+	assert.Len(t, client.Queue, 0)
+}
+
 func createTestServer(expected string, t *testing.T) (*httptest.Server, string) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
@@ -86,6 +167,14 @@ func createTestServer(expected string, t *testing.T) (*httptest.Server, string)
 	return ts, strings.Replace(ts.URL, "http://", "", 1)
 }
 
+func createTestServerWith503() (*httptest.Server, string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "Try again later")
+	}))
+	return ts, strings.Replace(ts.URL, "http://", "", 1)
+}
+
 func createTestServerWith404() (*httptest.Server, string) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -93,3 +182,264 @@ func createTestServerWith404() (*httptest.Server, string) {
 	}))
 	return ts, strings.Replace(ts.URL, "http://", "", 1)
 }
+
+func TestClientFlush(t *testing.T) {
+	var metricsRecived int64
+	ts, host := createCountingServer(http.StatusNoContent, &metricsRecived, t)
+	defer ts.Close()
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	// Worker timeout is big enough, so only Flush will send datapoints
+	client.StartWorkers(2, 10, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.EqualValues(t, 3, atomic.LoadInt64(&metricsRecived))
+	assert.EqualValues(t, 3, atomic.LoadInt64(&client.Sent))
+
+	// client is still usable after Flush
+	assert.NoError(t, client.Push(&DataPoint{"test1", 124, 1, Tags{"key": "val"}}))
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.EqualValues(t, 4, atomic.LoadInt64(&metricsRecived))
+
+	stats := client.Stats()
+	assert.EqualValues(t, 4, stats.Sent)
+	assert.Zero(t, stats.Queued)
+	assert.True(t, stats.RawBytes > 0)
+	assert.True(t, stats.WireBytes > 0)
+}
+
+func TestClientFlushWithCancelledContext(t *testing.T) {
+	var received int64
+	client := NewClientWithSenders(10, func() Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			atomic.AddInt64(&received, int64(len(batch)))
+			return nil
+		})
+	})
+	client.StartWorkers(1, 10, time.Hour)
+	defer client.Close(context.Background())
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := client.Flush(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), context.Canceled.Error())
+	}
+
+	// nothing is lost, datapoints are sent by next Flush
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.EqualValues(t, 5, atomic.LoadInt64(&received))
+	assert.EqualValues(t, 5, atomic.LoadInt64(&client.Sent))
+	assert.Zero(t, atomic.LoadInt64(&client.Dropped))
+}
+
+func TestClientClose(t *testing.T) {
+	var metricsRecived int64
+	ts, host := createCountingServer(http.StatusNoContent, &metricsRecived, t)
+	defer ts.Close()
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	client.StartWorkers(2, 10, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, client.Close(ctx))
+	assert.EqualValues(t, 5, atomic.LoadInt64(&metricsRecived))
+
+	assert.Equal(t, ErrClientClosed, client.Push(dps[0]))
+	assert.Equal(t, ErrClientClosed, client.Flush(ctx))
+	assert.Equal(t, ErrClientClosed, client.Close(ctx))
+}
+
+func TestClientCloseWithFailedSend(t *testing.T) {
+	var metricsRecived int64
+	ts, host := createCountingServer(http.StatusNotFound, &metricsRecived, t)
+	defer ts.Close()
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	client.StartWorkers(1, 10, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = client.Close(ctx)
+	assert.EqualError(t, err, `failed to deliver 5 datapoints on close: unexpected status 404 ("")`)
+	assert.EqualValues(t, 0, client.Sent)
+}
+
+func createCountingServer(status int, counter *int64, t *testing.T) (*httptest.Server, string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := gzipBodyReader(r.Body)
+		assert.NoError(t, err)
+
+		data := []interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(body), &data))
+		if status == http.StatusNoContent {
+			atomic.AddInt64(counter, int64(len(data)))
+		}
+		w.WriteHeader(status)
+	}))
+	return ts, strings.Replace(ts.URL, "http://", "", 1)
+}
+
+func TestPushWithDropOldest(t *testing.T) {
+	client, err := NewClient("localhost:4242", 2, time.Second)
+	assert.NoError(t, err)
+	client.Overflow = DropOldest
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.EqualValues(t, 1, client.Dropped)
+	assert.Equal(t, 1, (<-client.Queue).Value)
+	assert.Equal(t, 2, (<-client.Queue).Value)
+}
+
+func TestPushWithDropOldestUnbuffered(t *testing.T) {
+	client := NewClientWithSenders(0, nil)
+	client.Overflow = DropOldest
+
+	assert.Equal(t, ErrQueueFull, client.Push(dps[0]))
+	assert.EqualValues(t, 1, client.Dropped)
+}
+
+func TestRequeueWithBlock(t *testing.T) {
+	client := NewClientWithSenders(1, nil)
+	client.Overflow = Block
+	assert.NoError(t, client.Push(dps[0]))
+
+	// requeue waits for room even without BlockTimeout
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-client.Queue
+	}()
+	assert.NoError(t, client.requeue(dps[1]))
+	assert.EqualValues(t, 0, client.Dropped)
+	assert.Equal(t, dps[1], <-client.Queue)
+}
+
+func TestPushWithBlockWithTimeout(t *testing.T) {
+	client, err := NewClient("localhost:4242", 1, time.Second)
+	assert.NoError(t, err)
+	client.Overflow = BlockWithTimeout
+	client.BlockTimeout = 10 * time.Millisecond
+
+	assert.NoError(t, client.Push(dps[0]))
+	assert.EqualError(t, client.Push(dps[1]), "failed to push datapoint: context deadline exceeded")
+	assert.EqualValues(t, 1, client.Dropped)
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		<-client.Queue
+	}()
+	assert.NoError(t, client.Push(dps[1]))
+}
+
+func TestPushContext(t *testing.T) {
+	client, err := NewClient("localhost:4242", 1, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, client.PushContext(context.Background(), dps[0]))
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		<-client.Queue
+	}()
+	assert.NoError(t, client.PushContext(context.Background(), dps[1]))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.EqualError(t, client.PushContext(ctx, dps[0]), "failed to push datapoint: context deadline exceeded")
+	assert.EqualValues(t, 1, client.Dropped)
+}
+
+func TestClientCloseWithUndrainedErrors(t *testing.T) {
+	client := NewClientWithSenders(10, func() Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			return &PutError{StatusCode: http.StatusServiceUnavailable, Body: "tsdb is down"}
+		})
+	})
+	client.StartWorkers(1, 1, time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	// failed batches are requeued until Errors is full and worker is stuck
+	for len(client.Errors) < cap(client.Errors) {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close(context.Background()) }()
+	select {
+	case err := <-closed:
+		assert.EqualError(t, err, `failed to deliver 5 datapoints on close: unexpected status 503 ("tsdb is down")`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close is stuck on undrained Errors")
+	}
+}
+
+func TestClientCloseWaitsForCancelledWorkers(t *testing.T) {
+	var inflight int64
+	started := make(chan struct{}, 1)
+	client := NewClientWithSenders(10, func() Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			atomic.AddInt64(&inflight, 1)
+			defer atomic.AddInt64(&inflight, -1)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	})
+	client.StartWorkers(1, 1, time.Hour)
+	assert.NoError(t, client.Push(dps[0]))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := client.Close(ctx)
+	assert.EqualError(t, err, "failed to deliver 1 datapoints on close: context deadline exceeded")
+	assert.Zero(t, atomic.LoadInt64(&inflight))
+	assert.Len(t, client.Queue, 0)
+}
+
+func TestClientCloseCountsLateWorkers(t *testing.T) {
+	client := NewClientWithSenders(10, func() Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	})
+	client.StartWorkers(1, 10, time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.Close(ctx)
+	assert.EqualError(t, err, "failed to deliver 5 datapoints on close: context deadline exceeded")
+	assert.Len(t, client.Queue, 0)
+}
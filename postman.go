@@ -3,37 +3,101 @@ package opentsdb
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 // Postman is http client for POSTing of gzip json to TSDB
 type Postman struct {
-	client *http.Client
-	buffer bytes.Buffer
-	writer *gzip.Writer
+	// URL is /api/put url that is used by Send
+	URL string
+
+	// Traffic counts sizes of sent request bodies, if it's not nil
+	Traffic *Traffic
+
+	config  *HTTPConfig
+	client  *http.Client
+	raw     bytes.Buffer
+	buffer  bytes.Buffer
+	writer  *gzip.Writer
+	encoder jsonEncoder
+}
+
+// Traffic counts sizes of request bodies before and after compression, it's
+// safe for concurrent use
+type Traffic struct {
+	// Raw is size of encoded batches
+	Raw int64
+	// Wire is size of request bodies as they were sent, it's equal to Raw
+	// without compression
+	Wire int64
+}
+
+func (traffic *Traffic) add(raw, wire int) {
+	if traffic != nil {
+		atomic.AddInt64(&traffic.Raw, int64(raw))
+		atomic.AddInt64(&traffic.Wire, int64(wire))
+	}
 }
 
 // NewPostman initialize http.Client and all needed buffers for new new Postman
 func NewPostman(timeout time.Duration) *Postman {
+	return NewPostmanWithConfig(&HTTPConfig{Timeout: timeout})
+}
+
+// NewPostmanWithConfig initialize Postman with given HTTP settings, like TLS
+// and authorization
+func NewPostmanWithConfig(config *HTTPConfig) *Postman {
 	postman := &Postman{
-		client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost: 100,
-			},
-		},
-	}
-	postman.writer = gzip.NewWriter(&postman.buffer)
+		config: config,
+		client: config.NewHTTPClient(),
+	}
+	level := config.GzipLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	writer, err := gzip.NewWriterLevel(&postman.buffer, level)
+	if err != nil {
+		writer = gzip.NewWriter(&postman.buffer)
+	}
+	postman.writer = writer
 	return postman
 }
 
-// Post will make POST request to OpenTSDB at given url and verify response
+// PutResponse is body of /api/put response when ?summary or ?details is used:
+// http://opentsdb.net/docs/build/html/api_http/put.html#response
+type PutResponse struct {
+	Success int64         `json:"success"`
+	Failed  int64         `json:"failed"`
+	Errors  []*PutFailure `json:"errors,omitempty"`
+}
+
+// PutFailure is datapoint rejected by OpenTSDB along with the reason
+type PutFailure struct {
+	DataPoint *DataPoint `json:"datapoint"`
+	Error     string     `json:"error"`
+}
+
+// Post will make POST request to OpenTSDB at given url and verify response.
+// If url has ?details or ?summary and OpenTSDB rejected some of datapoints,
+// returned error will be *RejectedError, for other unexpected responses it
+// will be *PutError
 func (postman *Postman) Post(batch DataPoints, url string) (err error) {
-	resp, err := postman.makeHTTPRequest(batch, url)
+	return postman.post(context.Background(), batch, url)
+}
+
+// Send will POST batch to postman.URL, it implements Sender
+func (postman *Postman) Send(ctx context.Context, batch DataPoints) error {
+	return postman.post(ctx, batch, postman.URL)
+}
+
+func (postman *Postman) post(ctx context.Context, batch DataPoints, url string) error {
+	resp, err := postman.makeHTTPRequest(ctx, batch, url)
 	if err == nil {
 		// Callers should close resp.Body when done reading from it.
 		defer resp.Body.Close()
@@ -42,32 +106,78 @@ func (postman *Postman) Post(batch DataPoints, url string) (err error) {
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusNoContent {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response: %v", err)
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusBadRequest {
+		var result PutResponse
+		// numbers are kept as is, so rejected values are reported exactly
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&result); err == nil {
+			if result.Failed > 0 {
+				return &RejectedError{result}
+			}
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
 		}
-		return fmt.Errorf("unexpected status %d (%q)", resp.StatusCode, string(body))
 	}
-	return nil
+	return newPutError(resp.StatusCode, body, len(batch))
 }
 
-func (postman *Postman) makeHTTPRequest(dps DataPoints, tsdbURL string) (*http.Response, error) {
-	postman.writer.Reset(&postman.buffer)
-	// TODO: try https://github.com/pquerna/ffjson
-	if err := json.NewEncoder(postman.writer).Encode(dps); err != nil {
-		return nil, err
-	}
-	if err := postman.writer.Close(); err != nil {
+func (postman *Postman) makeHTTPRequest(ctx context.Context, dps DataPoints, tsdbURL string) (*http.Response, error) {
+	body, raw, compressed, err := postman.encode(dps)
+	if err != nil {
 		return nil, err
 	}
+	wire := body.Len()
 
-	req, err := http.NewRequest("POST", tsdbURL, &postman.buffer)
+	req, err := http.NewRequestWithContext(ctx, "POST", tsdbURL, body)
 	if err != nil {
 		return nil, err
 	}
 
+	postman.config.authorize(req)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", "gzip")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	postman.Traffic.add(raw, wire)
 	return postman.client.Do(req)
 }
+
+// encode returns request body for dps and its uncompressed size. Batch is
+// streamed right into gzip writer, unless it has to be measured first for
+// CompressMinSize
+func (postman *Postman) encode(dps DataPoints) (*bytes.Buffer, int, bool, error) {
+	config := postman.config
+	postman.buffer.Reset()
+	if !config.DisableCompression && config.CompressMinSize <= 0 {
+		postman.writer.Reset(&postman.buffer)
+		raw, err := postman.encoder.encode(postman.writer, dps)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return &postman.buffer, raw, true, postman.writer.Close()
+	}
+
+	postman.raw.Reset()
+	raw, err := postman.encoder.encode(&postman.raw, dps)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if config.DisableCompression || raw < config.CompressMinSize {
+		return &postman.raw, raw, false, nil
+	}
+	postman.writer.Reset(&postman.buffer)
+	if _, err := postman.writer.Write(postman.raw.Bytes()); err != nil {
+		return nil, 0, false, err
+	}
+	return &postman.buffer, raw, true, postman.writer.Close()
+}
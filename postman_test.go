@@ -2,6 +2,7 @@ package opentsdb
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -33,6 +34,52 @@ func TestSendDatapoints(t *testing.T) {
 	assert.NoError(t, postman.Post(dps, ts.URL))
 }
 
+func TestSendCompression(t *testing.T) {
+	var encodings []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		var body string
+		var err error
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, err = gzipBodyReader(r.Body)
+		} else {
+			var data []byte
+			data, err = ioutil.ReadAll(r.Body)
+			body = string(data)
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"metric":"test1","timestamp":123,"value":1,"tags":{"key":"val"}}]`+"\n", body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dps := DataPoints{&DataPoint{"test1", 123, 1, Tags{"key": "val"}}}
+	rawSize := int64(len(`[{"metric":"test1","timestamp":123,"value":1,"tags":{"key":"val"}}]`) + 1)
+
+	var traffic Traffic
+	config := &HTTPConfig{Timeout: time.Second, DisableCompression: true}
+	postman := NewPostmanWithConfig(config)
+	postman.Traffic = &traffic
+	assert.NoError(t, postman.Post(dps, ts.URL))
+	assert.Equal(t, rawSize, traffic.Raw)
+	assert.Equal(t, rawSize, traffic.Wire)
+
+	// batch is smaller than threshold
+	config.DisableCompression = false
+	config.CompressMinSize = 1024
+	assert.NoError(t, postman.Post(dps, ts.URL))
+
+	config.CompressMinSize = 10
+	assert.NoError(t, postman.Post(dps, ts.URL))
+
+	config.CompressMinSize = 0
+	assert.NoError(t, postman.Post(dps, ts.URL))
+
+	assert.Equal(t, []string{"", "", "gzip", "gzip"}, encodings)
+	assert.Equal(t, 4*rawSize, traffic.Raw)
+	assert.True(t, traffic.Wire > 2*rawSize)
+}
+
 func TestSendWithTimeout(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(50 * time.Millisecond)
@@ -50,6 +97,39 @@ func TestSendWithTimeout(t *testing.T) {
 	assert.EqualError(t, err, expected)
 }
 
+func TestSendWithRejectedDatapoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "details", r.URL.RawQuery)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"success":1,"failed":1,"errors":[{"datapoint":`+
+			`{"metric":"test2","timestamp":234,"value":2,"tags":{"type":"test"}},`+
+			`"error":"Unable to parse value to a number"}]}`)
+	}))
+	defer ts.Close()
+
+	postman := NewPostman(5 * time.Second)
+	err := postman.Post(dps, ts.URL+"?details")
+	assert.EqualError(t, err, `1 of 2 datapoints rejected, first: "Unable to parse value to a number" `+
+		`for test2 234 2 type=test`)
+
+	rejected, ok := err.(*RejectedError)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, rejected.Success)
+	assert.Len(t, rejected.Errors, 1)
+	assert.Equal(t, "test2", rejected.Errors[0].DataPoint.Metric)
+}
+
+func TestSendWithDetailsSuccess(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"success":2,"failed":0,"errors":[]}`)
+	}))
+	defer ts.Close()
+
+	postman := NewPostman(5 * time.Second)
+	assert.NoError(t, postman.Post(dps, ts.URL+"?details"))
+}
+
 func BenchmarkPost(b *testing.B) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
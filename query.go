@@ -0,0 +1,294 @@
+package opentsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// QueryClient is http client for reading from OpenTSDB HTTP api, like
+// /api/query. It uses the same HTTPConfig as Postman, so TLS, authorization
+// and custom headers are shared
+type QueryClient struct {
+	config *HTTPConfig
+	client *http.Client
+	base   *url.URL
+}
+
+// NewQueryClient creates QueryClient for TSD at host, that is host:port or url
+// like https://tsdb:4242/prefix. If config is nil, credentials from host url
+// and given timeout are used
+func NewQueryClient(host string, config *HTTPConfig, timeout time.Duration) (*QueryClient, error) {
+	base, err := parseHost(host)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = newHTTPConfig(timeout, base)
+	}
+	return newQueryClient(base, config), nil
+}
+
+func newQueryClient(base *url.URL, config *HTTPConfig) *QueryClient {
+	return &QueryClient{config: config, client: config.NewHTTPClient(), base: base}
+}
+
+// QueryClient returns QueryClient for the same TSD and with the same
+// HTTPConfig as client, for cluster client the first host is used with its
+// own credentials
+func (client *Client) QueryClient() (*QueryClient, error) {
+	if client.HTTP == nil || client.base == nil {
+		return nil, fmt.Errorf("client has no http endpoint")
+	}
+	return newQueryClient(client.base, withCredentials(client.HTTP, client.base.User)), nil
+}
+
+// Query is body of /api/query request:
+// http://opentsdb.net/docs/build/html/api_http/query/index.html
+type Query struct {
+	// Start is required, End is now if it's zero
+	Start time.Time
+	End   time.Time
+
+	Queries []*SubQuery
+
+	// MsResolution returns points with millisecond timestamps
+	MsResolution bool
+	// ShowTSUIDs adds TSUIDs of time series to results
+	ShowTSUIDs bool
+	// ShowQuery adds subquery to every result
+	ShowQuery bool
+	// Timezone is used for calendar downsampling
+	Timezone string
+	// UseCalendar aligns downsampling to calendar
+	UseCalendar bool
+	// NoAnnotations skips annotations of series in results
+	NoAnnotations bool
+	// GlobalAnnotations adds global annotations to every result
+	GlobalAnnotations bool
+}
+
+// SubQuery is one metric query of Query
+type SubQuery struct {
+	// Aggregator is e.g. sum, avg, max or none, see /api/aggregators
+	Aggregator string `json:"aggregator"`
+	Metric     string `json:"metric"`
+	// Downsample is e.g. 1m-avg or 1h-sum-zero
+	Downsample  string       `json:"downsample,omitempty"`
+	Rate        bool         `json:"rate,omitempty"`
+	RateOptions *RateOptions `json:"rateOptions,omitempty"`
+	// Tags are group by tags in OpenTSDB 2.0 style, like host=*
+	Tags    Tags      `json:"tags,omitempty"`
+	Filters []*Filter `json:"filters,omitempty"`
+	// ExplicitTags returns only series with exactly given tags
+	ExplicitTags bool `json:"explicitTags,omitempty"`
+}
+
+// RateOptions tunes rate calculation for counters
+type RateOptions struct {
+	Counter    bool  `json:"counter"`
+	CounterMax int64 `json:"counterMax,omitempty"`
+	ResetValue int64 `json:"resetValue,omitempty"`
+	DropResets bool  `json:"dropResets,omitempty"`
+}
+
+// Filter is tag filter of SubQuery:
+// http://opentsdb.net/docs/build/html/user_guide/query/filters.html
+type Filter struct {
+	// Type is e.g. literal_or, wildcard or regexp
+	Type    string `json:"type"`
+	Tagk    string `json:"tagk"`
+	Filter  string `json:"filter"`
+	GroupBy bool   `json:"groupBy"`
+}
+
+// MarshalJSON encodes q with start and end as millisecond timestamps, Start
+// is required
+func (q *Query) MarshalJSON() ([]byte, error) {
+	if q.Start.IsZero() {
+		return nil, fmt.Errorf("query start time is not set")
+	}
+	body := struct {
+		Start             int64       `json:"start"`
+		End               int64       `json:"end,omitempty"`
+		Queries           []*SubQuery `json:"queries"`
+		MsResolution      bool        `json:"msResolution,omitempty"`
+		ShowTSUIDs        bool        `json:"showTSUIDs,omitempty"`
+		ShowQuery         bool        `json:"showQuery,omitempty"`
+		Timezone          string      `json:"timezone,omitempty"`
+		UseCalendar       bool        `json:"useCalendar,omitempty"`
+		NoAnnotations     bool        `json:"noAnnotations,omitempty"`
+		GlobalAnnotations bool        `json:"globalAnnotations,omitempty"`
+	}{
+		Start:             Timestamp(q.Start, Milliseconds),
+		Queries:           q.Queries,
+		MsResolution:      q.MsResolution,
+		ShowTSUIDs:        q.ShowTSUIDs,
+		ShowQuery:         q.ShowQuery,
+		Timezone:          q.Timezone,
+		UseCalendar:       q.UseCalendar,
+		NoAnnotations:     q.NoAnnotations,
+		GlobalAnnotations: q.GlobalAnnotations,
+	}
+	if !q.End.IsZero() {
+		body.End = Timestamp(q.End, Milliseconds)
+	}
+	return json.Marshal(body)
+}
+
+// Series is one time series of /api/query response
+type Series struct {
+	Metric string `json:"metric"`
+	Tags   Tags   `json:"tags"`
+	// AggregateTags are tags that had different values in aggregated series
+	AggregateTags []string  `json:"aggregateTags"`
+	TSUIDs        []string  `json:"tsuids,omitempty"`
+	Query         *SubQuery `json:"query,omitempty"`
+	// Annotations of series and global ones, if query asked for them
+	Annotations       []*Annotation `json:"annotations,omitempty"`
+	GlobalAnnotations []*Annotation `json:"globalAnnotations,omitempty"`
+	// Points are ordered by timestamp
+	Points []Point `json:"-"`
+}
+
+// Point is one value of Series, Timestamp is in seconds or, with
+// MsResolution, in milliseconds
+type Point struct {
+	Timestamp int64
+	Value     Value
+}
+
+// UnmarshalJSON decodes series with dps as object or as array of pairs
+func (series *Series) UnmarshalJSON(data []byte) error {
+	type plain Series
+	var s struct {
+		*plain
+		DPS json.RawMessage `json:"dps"`
+	}
+	s.plain = (*plain)(series)
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	points, err := decodePoints(s.DPS)
+	if err != nil {
+		return fmt.Errorf("failed to decode dps of %s: %v", series.Metric, err)
+	}
+	series.Points = points
+	return nil
+}
+
+func decodePoints(data json.RawMessage) ([]Point, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var points []Point
+	if data[0] == '[' {
+		var pairs [][2]json.Number
+		if err := decoder.Decode(&pairs); err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			ts, err := pair[0].Int64()
+			if err != nil {
+				return nil, err
+			}
+			value, err := ParseValue(pair[1])
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, Point{ts, value})
+		}
+	} else {
+		var dps map[string]json.Number
+		if err := decoder.Decode(&dps); err != nil {
+			return nil, err
+		}
+		for key, number := range dps {
+			ts, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			value, err := ParseValue(number)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, Point{ts, value})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points, nil
+}
+
+// Query runs q and returns all series from response
+func (qc *QueryClient) Query(ctx context.Context, q *Query) ([]*Series, error) {
+	var series []*Series
+	if err := qc.do(ctx, "POST", "api/query", nil, q, &series); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// do makes request to api endpoint at path with body encoded as JSON, if it's
+// not nil, and decodes response into result. Unexpected responses are
+// returned as *APIError
+func (qc *QueryClient) do(ctx context.Context, method, path string, params url.Values, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := endpointURL(qc.base, path, params.Encode())
+	var req *http.Request
+	var err error
+	if reader != nil {
+		req, err = http.NewRequestWithContext(ctx, method, u, reader)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, u, nil)
+	}
+	if err != nil {
+		return err
+	}
+	qc.config.authorize(req)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := qc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp.StatusCode, data)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
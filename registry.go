@@ -0,0 +1,194 @@
+package opentsdb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Registry holds named metrics, that are registered once and reported
+// together, e.g. by Reporter. Every metric is identified by its name and
+// tags, Prefix is prepended to metric names and Tags are added to tags of
+// every datapoint, unless metric has its own value for the tag. Registry is
+// safe for concurrent use
+type Registry struct {
+	// Prefix is prepended to every metric name as is, e.g. "myapp."
+	Prefix string
+	// Tags are common tags for all metrics
+	Tags Tags
+
+	mu      sync.Mutex
+	metrics map[string]Metric
+	keys    []string
+}
+
+// NewRegistry creates Registry with given prefix and common tags
+func NewRegistry(prefix string, tags Tags) *Registry {
+	return &Registry{
+		Prefix:  prefix,
+		Tags:    tags,
+		metrics: make(map[string]Metric),
+	}
+}
+
+// Register adds metric under given name and tags, that should be unique.
+// Datapoints are reported with names and tags that metric itself gives them
+func (registry *Registry) Register(name string, tags Tags, metric Metric) error {
+	key := name + tags.String()
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.metrics[key]; ok {
+		return fmt.Errorf("metric %s is already registered", key)
+	}
+	registry.metrics[key] = metric
+	registry.keys = append(registry.keys, key)
+	return nil
+}
+
+// Unregister removes metric with given name and tags, it returns false if
+// there was no such metric
+func (registry *Registry) Unregister(name string, tags Tags) bool {
+	key := name + tags.String()
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.metrics[key]; !ok {
+		return false
+	}
+	delete(registry.metrics, key)
+	for i, k := range registry.keys {
+		if k == key {
+			registry.keys = append(registry.keys[:i], registry.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Get returns metric with given name and tags, or nil
+func (registry *Registry) Get(name string, tags Tags) Metric {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.metrics[name+tags.String()]
+}
+
+// Counter creates and registers Counter
+func (registry *Registry) Counter(name string, tags Tags, mode CounterMode) (*Counter, error) {
+	counter := NewCounter(name, tags, mode)
+	return counter, registry.Register(name, tags, counter)
+}
+
+// Gauge creates and registers Gauge
+func (registry *Registry) Gauge(name string, tags Tags) (*Gauge, error) {
+	gauge := NewGauge(name, tags)
+	return gauge, registry.Register(name, tags, gauge)
+}
+
+// Histogram creates and registers Histogram with given percentiles
+func (registry *Registry) Histogram(name string, tags Tags, percentiles ...float64) (*Histogram, error) {
+	histogram := NewHistogram(name, tags, percentiles...)
+	return histogram, registry.Register(name, tags, histogram)
+}
+
+// Collect implements Metric, it returns datapoints of all registered metrics
+// in order of registration with Prefix and Tags applied
+func (registry *Registry) Collect(timestamp int64) DataPoints {
+	registry.mu.Lock()
+	metrics := make([]Metric, 0, len(registry.keys))
+	for _, key := range registry.keys {
+		metrics = append(metrics, registry.metrics[key])
+	}
+	registry.mu.Unlock()
+
+	var dps DataPoints
+	for _, m := range metrics {
+		for _, dp := range m.Collect(timestamp) {
+			dps = append(dps, registry.apply(dp))
+		}
+	}
+	return dps
+}
+
+// apply returns copy of dp with Prefix and Tags
+func (registry *Registry) apply(dp *DataPoint) *DataPoint {
+	if registry.Prefix == "" && len(registry.Tags) == 0 {
+		return dp
+	}
+	tags := make(Tags, len(dp.Tags)+len(registry.Tags))
+	for key, value := range registry.Tags {
+		tags[key] = value
+	}
+	for key, value := range dp.Tags {
+		tags[key] = value
+	}
+	return &DataPoint{registry.Prefix + dp.Metric, dp.Timestamp, dp.Value, tags}
+}
+
+// Reporter pushes datapoints of metrics, usually Registry, to Client every
+// interval with timestamps aligned to interval. Once started, it makes final
+// report when client is closed, so values collected since the last interval
+// are not lost
+type Reporter struct {
+	client   *Client
+	metrics  Metric
+	interval time.Duration
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// NewReporter creates Reporter of metrics to client, DefaultFlushInterval is
+// used if interval is not positive
+func NewReporter(client *Client, metrics Metric, interval time.Duration) *Reporter {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	return &Reporter{client: client, metrics: metrics, interval: interval}
+}
+
+// Start begins periodic reports, their errors are passed to client Errors if
+// there is room for them. Stopped Reporter can't be started again
+func (reporter *Reporter) Start() {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if reporter.stop != nil || reporter.stopped {
+		return
+	}
+	reporter.stop = make(chan struct{})
+	reporter.done = make(chan struct{})
+	go schedule(reporter.interval, reporter.stop, reporter.done, func(t time.Time) {
+		reporter.client.offer(reporter.report(t))
+	})
+	reporter.client.atClose(func() { reporter.Stop() })
+}
+
+// Stop stops periodic reports and makes the final one, it's called by client
+// Close, so it's not necessary to call it before that
+func (reporter *Reporter) Stop() error {
+	reporter.mu.Lock()
+	if reporter.stopped {
+		reporter.mu.Unlock()
+		return nil
+	}
+	reporter.stopped = true
+	stop, done := reporter.stop, reporter.done
+	reporter.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return reporter.Report()
+}
+
+// Report pushes current values of metrics with timestamp of current interval
+func (reporter *Reporter) Report() error {
+	return reporter.report(time.Now())
+}
+
+func (reporter *Reporter) report(now time.Time) error {
+	return pushAll(reporter.client, reporter.metrics.Collect(alignedTimestamp(now, reporter.interval)))
+}
@@ -0,0 +1,88 @@
+package opentsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry("myapp.", Tags{"host": "web01", "dc": "eu"})
+
+	requests, err := registry.Counter("requests", Tags{"handler": "index"}, Cumulative)
+	assert.NoError(t, err)
+	requests.Add(5)
+	_, err = registry.Counter("requests", Tags{"handler": "index"}, Delta)
+	assert.EqualError(t, err, "metric requests{handler=index} is already registered")
+
+	memory, err := registry.Gauge("memory", Tags{"host": "web02"})
+	assert.NoError(t, err)
+	memory.Set(0.5)
+	assert.Equal(t, memory, registry.Get("memory", Tags{"host": "web02"}))
+
+	assert.Equal(t, DataPoints{
+		{"myapp.requests", 123, Int(5), Tags{"host": "web01", "dc": "eu", "handler": "index"}},
+		// metric tags override common ones
+		{"myapp.memory", 123, Float(0.5), Tags{"host": "web02", "dc": "eu"}},
+	}, registry.Collect(123))
+	// metrics are not changed by registry
+	assert.Equal(t, Tags{"handler": "index"}, requests.Tags)
+
+	assert.True(t, registry.Unregister("requests", Tags{"handler": "index"}))
+	assert.False(t, registry.Unregister("requests", Tags{"handler": "index"}))
+	assert.Len(t, registry.Collect(124), 1)
+}
+
+func TestReporterFinalReportOnClose(t *testing.T) {
+	sender := &recorder{}
+	client := NewClientWithSenders(100, func() Sender { return sender })
+	client.StartWorkers(1, 100, time.Hour)
+
+	registry := NewRegistry("", nil)
+	counter, err := registry.Counter("requests", Tags{"host": "web01"}, Delta)
+	assert.NoError(t, err)
+
+	reporter := NewReporter(client, registry, time.Hour)
+	reporter.Start()
+	counter.Add(3)
+
+	assert.NoError(t, client.Close(context.Background()))
+	assert.Equal(t, 1, sender.count())
+	assert.Equal(t, Int(3), sender.batches[0][0].Value)
+
+	// reporter was stopped by Close
+	assert.NoError(t, reporter.Stop())
+	assert.Equal(t, 1, sender.count())
+}
+
+func TestReporterPeriodic(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	registry := NewRegistry("", nil)
+	gauge, err := registry.Gauge("memory", Tags{"host": "web01"})
+	assert.NoError(t, err)
+	gauge.Set(1)
+
+	reporter := NewReporter(client, registry, 20*time.Millisecond)
+	reporter.Start()
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, reporter.Stop())
+	assert.True(t, len(client.Queue) >= 3, "%d reports", len(client.Queue))
+}
+
+func TestReporterWithZeroInterval(t *testing.T) {
+	client := NewClientWithSenders(100, nil)
+	registry := NewRegistry("", nil)
+	gauge, err := registry.Gauge("memory", nil)
+	assert.NoError(t, err)
+	gauge.Set(1)
+
+	reporter := NewReporter(client, registry, 0)
+	assert.Equal(t, DefaultFlushInterval, reporter.interval)
+	reporter.Start()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, reporter.Stop())
+	// only the final report
+	assert.Len(t, client.Queue, 1)
+}
@@ -0,0 +1,97 @@
+package opentsdb
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy defines how worker retries failed batch before giving up on it.
+// Batch is retried by the same worker, so it's not competing with fresh
+// datapoints for room in Queue
+type RetryPolicy struct {
+	// InitialBackoff is delay before first retry
+	InitialBackoff time.Duration
+	// MaxBackoff limits delay between retries
+	MaxBackoff time.Duration
+	// Multiplier is applied to delay after every retry
+	Multiplier float64
+	// Jitter is fraction of delay, that will be randomized, from 0 to 1
+	Jitter float64
+	// MaxAttempts is number of attempts including first one, 0 is unlimited
+	MaxAttempts int
+	// MaxAge is time since first attempt after which batch is dropped,
+	// 0 is unlimited
+	MaxAge time.Duration
+}
+
+// DefaultRetryPolicy is reasonable policy for most cases
+var DefaultRetryPolicy = RetryPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	MaxAttempts:    10,
+	MaxAge:         time.Minute,
+}
+
+// Backoff returns delay before given retry, retries are counted from 1
+func (policy *RetryPolicy) Backoff(retry int) time.Duration {
+	delay := float64(policy.InitialBackoff)
+	for i := 1; i < retry && policy.Multiplier > 0; i++ {
+		delay *= policy.Multiplier
+		if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+			break
+		}
+	}
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// retry sends batch until it succeeds, fails with error that is not retryable
+// or client.Retry gives up on it. Batch that exhausted its attempts is written
+// to client.Spool if there is one. Otherwise such batches are counted as
+// Failed, except rejected ones, and returned as *SendError
+func (client *Client) retry(ctx context.Context, sender Sender, batch DataPoints) error {
+	policy := client.Retry
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := client.post(ctx, sender, batch)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*RejectedError); ok {
+			return err
+		}
+		if tooLarge(err, batch) {
+			return client.split(ctx, sender, batch, client.retry)
+		}
+
+		delay := policy.Backoff(attempt)
+		exhausted := policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts
+		expired := policy.MaxAge > 0 && time.Since(start)+delay > policy.MaxAge
+		if IsRetryable(err) && !exhausted && !expired {
+			atomic.AddInt64(&client.Retried, int64(len(batch)))
+			select {
+			case <-time.After(delay):
+				continue
+			case <-ctx.Done():
+			case <-client.done:
+			}
+		}
+
+		// batch cancelled with ctx could still succeed later
+		spooled := 0
+		if IsRetryable(err) || ctx.Err() != nil {
+			spooled = client.spill(batch)
+		}
+		atomic.AddInt64(&client.Failed, int64(len(batch)-spooled))
+		return &SendError{Err: err, BatchSize: len(batch), Attempts: attempt, Spooled: spooled}
+	}
+}
@@ -0,0 +1,140 @@
+package opentsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.Backoff(4))
+	assert.Equal(t, time.Second, policy.Backoff(5))
+	assert.Equal(t, time.Second, policy.Backoff(100))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		assert.InDelta(t, 200*time.Millisecond, policy.Backoff(2), float64(100*time.Millisecond))
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Will fail first two requests
+		if atomic.AddInt64(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "http://", "", 1)
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	client.Retry = &RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 5}
+	client.StartWorkers(1, 2, 10*time.Millisecond)
+
+	assert.NoError(t, client.Push(dps[0]))
+	assert.NoError(t, client.Push(dps[1]))
+	time.Sleep(50 * time.Millisecond)
+
+	assert.EqualValues(t, 4, atomic.LoadInt64(&requests))
+	assert.EqualValues(t, 2, atomic.LoadInt64(&client.Sent))
+	assert.EqualValues(t, 2, atomic.LoadInt64(&client.Retried))
+	assert.EqualValues(t, 0, atomic.LoadInt64(&client.Failed))
+	assert.Len(t, client.Queue, 0)
+}
+
+func TestClientRetryGiveUp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "http://", "", 1)
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	client.Retry = &RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3}
+	client.StartWorkers(1, 1, 10*time.Millisecond)
+
+	assert.NoError(t, client.Push(dps[0]))
+
+	select {
+	case err := <-client.Errors:
+		assert.EqualError(t, err, `request failed: unexpected status 503 ("") (gave up on 1 datapoints after 3 attempts)`)
+	case <-time.After(time.Second):
+		t.Fatal("expected error from worker")
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt64(&client.Failed))
+	assert.EqualValues(t, 2, atomic.LoadInt64(&client.Retried))
+	assert.Len(t, client.Queue, 0)
+}
+
+func TestClientRetryNotRetryable(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	host := strings.Replace(ts.URL, "http://", "", 1)
+
+	client, err := NewClient(host, 10, time.Second)
+	assert.NoError(t, err)
+	client.Retry = &RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3}
+	client.StartWorkers(1, 1, 10*time.Millisecond)
+
+	assert.NoError(t, client.Push(dps[0]))
+
+	select {
+	case err := <-client.Errors:
+		assert.EqualError(t, err, `request failed: unexpected status 400 ("") (gave up on 1 datapoints after 1 attempts)`)
+	case <-time.After(time.Second):
+		t.Fatal("expected error from worker")
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt64(&requests))
+	assert.EqualValues(t, 0, atomic.LoadInt64(&client.Retried))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&client.Failed))
+}
+
+func TestClientRetryCancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+
+	client := NewClientWithSenders(10, func() Sender { return &recorder{} })
+	client.Spool = spool
+	client.Retry = &RetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sender := SenderFunc(func(ctx context.Context, batch DataPoints) error {
+		return ctx.Err()
+	})
+	err = client.retry(ctx, sender, dps)
+	if assert.IsType(t, &SendError{}, err) {
+		assert.Equal(t, 2, err.(*SendError).Spooled)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt64(&client.Spooled))
+	assert.EqualValues(t, 0, atomic.LoadInt64(&client.Failed))
+}
@@ -0,0 +1,26 @@
+package opentsdb
+
+import (
+	"context"
+)
+
+// Sender delivers batch of datapoints to OpenTSDB. Postman and Telnet
+// implement it, but it could be any other protocol, recorder or fake.
+// Sender is used by one worker at a time, so it doesn't need to be safe for
+// concurrent use. If it implements io.Closer, it will be closed when worker
+// stops
+type Sender interface {
+	Send(ctx context.Context, batch DataPoints) error
+}
+
+// SenderFactory creates Sender for every worker, so each one could keep its
+// own state, like Postman's gzip buffer or Telnet's connection
+type SenderFactory func() Sender
+
+// SenderFunc is adapter to allow use of ordinary function as Sender
+type SenderFunc func(ctx context.Context, batch DataPoints) error
+
+// Send calls fn(ctx, batch)
+func (fn SenderFunc) Send(ctx context.Context, batch DataPoints) error {
+	return fn(ctx, batch)
+}
@@ -0,0 +1,69 @@
+package opentsdb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is fake Sender, that remembers all batches
+type recorder struct {
+	sync.Mutex
+	batches []DataPoints
+	err     error
+}
+
+func (r *recorder) Send(ctx context.Context, batch DataPoints) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	n := 0
+	for _, batch := range r.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func TestClientWithSenders(t *testing.T) {
+	rec := &recorder{}
+	var created int64
+	client := NewClientWithSenders(10, func() Sender {
+		atomic.AddInt64(&created, 1)
+		return rec
+	})
+	client.StartWorkers(3, 2, time.Hour)
+	assert.EqualValues(t, 3, created)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.NoError(t, client.Close(context.Background()))
+	assert.Equal(t, 5, rec.count())
+	assert.EqualValues(t, 5, client.Sent)
+}
+
+func TestClientSendWithSenderFunc(t *testing.T) {
+	client := NewClientWithSenders(2, nil)
+
+	var got DataPoints
+	sender := SenderFunc(func(ctx context.Context, batch DataPoints) error {
+		got = batch
+		return nil
+	})
+	assert.NoError(t, client.Send(sender, dps))
+	assert.Equal(t, dps, got)
+	assert.EqualValues(t, 2, client.Sent)
+}
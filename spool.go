@@ -0,0 +1,504 @@
+package opentsdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSpoolFull is returned by Spool.Write if MaxSize would be exceeded
+var ErrSpoolFull = errors.New("spool is full")
+
+// SyncPolicy defines when Spool calls fsync on segment files
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing of segment files to OS
+	SyncNever SyncPolicy = iota
+	// SyncAlways will fsync segment after every write
+	SyncAlways
+	// SyncInterval will fsync segment on write, if previous fsync was more
+	// than Spool.SyncEvery ago
+	SyncInterval
+)
+
+const (
+	segmentExt = ".seg"
+	cursorFile = "cursor"
+)
+
+// Spool is disk-backed FIFO for datapoints. Datapoints are appended as JSON
+// lines to segment files in dir, and read position is kept in cursor file,
+// so content of spool survives process restarts. Segments are removed once
+// they are fully read and committed.
+// Spool is safe for concurrent use, but it expects single reader.
+type Spool struct {
+	// MaxSegmentSize is size of segment file after which new one is started
+	MaxSegmentSize int64
+	// MaxSize limits size of datapoints that weren't committed yet, 0 is
+	// unlimited
+	MaxSize int64
+	// Sync is fsync policy for writes
+	Sync SyncPolicy
+	// SyncEvery is interval for SyncInterval policy
+	SyncEvery time.Duration
+	// ReplayInterval is how often Client checks spool for datapoints to
+	// replay and probes TSD after failure
+	ReplayInterval time.Duration
+
+	dir      string
+	mu       sync.Mutex
+	segments []int64
+	sizes    map[int64]int64
+	size     int64
+	file     *os.File
+	fileID   int64
+	lastSync time.Time
+	cursor   spoolPosition
+	next     spoolPosition
+	buffer   bytes.Buffer
+}
+
+type spoolPosition struct {
+	segment int64
+	offset  int64
+}
+
+// OpenSpool will open spool in given dir, creating dir if it doesn't exist.
+// Datapoints left from previous run will be available for Read
+func OpenSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %v", err)
+	}
+
+	spool := &Spool{
+		MaxSegmentSize: 64 << 20,
+		SyncEvery:      time.Second,
+		ReplayInterval: time.Second,
+		dir:            dir,
+		sizes:          make(map[int64]int64),
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool dir: %v", err)
+	}
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		spool.segments = append(spool.segments, id)
+		spool.sizes[id] = file.Size()
+		spool.size += file.Size()
+	}
+	sort.Slice(spool.segments, func(i, j int) bool { return spool.segments[i] < spool.segments[j] })
+
+	if err := spool.loadCursor(); err != nil {
+		return nil, err
+	}
+	spool.next = spool.cursor
+	if err := spool.cleanup(); err != nil {
+		return nil, err
+	}
+	return spool, nil
+}
+
+// Write appends batch to the spool
+func (spool *Spool) Write(batch DataPoints) error {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	spool.buffer.Reset()
+	encoder := json.NewEncoder(&spool.buffer)
+	for _, dp := range batch {
+		if err := encoder.Encode(dp); err != nil {
+			return err
+		}
+	}
+	n := int64(spool.buffer.Len())
+
+	// segment we are writing to is removed once it's fully committed, so
+	// disk space is reclaimed without waiting for MaxSegmentSize
+	consumed := spool.file != nil && spool.cursor.segment == spool.fileID &&
+		spool.cursor.offset >= spool.sizes[spool.fileID]
+	if consumed {
+		if err := spool.rotate(); err != nil {
+			return err
+		}
+		if err := spool.cleanup(); err != nil {
+			return err
+		}
+	}
+
+	if spool.MaxSize > 0 && spool.size-spool.cursor.offset+n > spool.MaxSize {
+		return ErrSpoolFull
+	}
+
+	if spool.file == nil || spool.sizes[spool.fileID] > 0 && spool.sizes[spool.fileID]+n > spool.MaxSegmentSize {
+		if err := spool.rotate(); err != nil {
+			return err
+		}
+	}
+
+	written, err := spool.file.Write(spool.buffer.Bytes())
+	spool.sizes[spool.fileID] += int64(written)
+	spool.size += int64(written)
+	if err != nil {
+		return fmt.Errorf("failed to write to spool: %v", err)
+	}
+
+	if spool.Sync == SyncAlways || spool.Sync == SyncInterval && time.Since(spool.lastSync) >= spool.SyncEvery {
+		spool.lastSync = time.Now()
+		return spool.file.Sync()
+	}
+	return nil
+}
+
+// Read returns up to n datapoints from the head of the spool. It doesn't
+// remove them, so next Read will return the same datapoints, until Commit
+// is called. Lines that can't be decoded are skipped
+func (spool *Spool) Read(n int) (DataPoints, error) {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	batch := make(DataPoints, 0)
+	pos := spool.cursor
+	for _, id := range spool.segments {
+		if id < pos.segment {
+			continue
+		}
+		if id > pos.segment {
+			pos = spoolPosition{segment: id}
+		}
+
+		offset, err := spool.readSegment(pos, n, &batch)
+		if err != nil {
+			return nil, err
+		}
+		pos.offset = offset
+		if len(batch) >= n {
+			break
+		}
+	}
+	spool.next = pos
+	return batch, nil
+}
+
+// readSegment appends datapoints from segment, starting at pos, to batch
+// until it has n datapoints or segment ends, and returns offset it stopped at
+func (spool *Spool) readSegment(pos spoolPosition, n int, batch *DataPoints) (int64, error) {
+	file, err := os.Open(spool.segmentPath(pos.segment))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spool segment: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(pos.offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read spool segment: %v", err)
+	}
+
+	offset := pos.offset
+	reader := bufio.NewReader(file)
+	for len(*batch) < n {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// incomplete line is left from crash in the middle of write,
+			// it's skipped unless this is segment we are writing to
+			if pos.segment != spool.fileID || spool.file == nil {
+				offset += int64(len(line))
+			}
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read spool segment: %v", err)
+		}
+		offset += int64(len(line))
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		dp := &DataPoint{}
+		if err := decoder.Decode(dp); err != nil {
+			continue
+		}
+		*batch = append(*batch, dp)
+	}
+	return offset, nil
+}
+
+// Commit removes datapoints returned by last Read from the spool
+func (spool *Spool) Commit() error {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	spool.cursor = spool.next
+	if err := spool.saveCursor(); err != nil {
+		return err
+	}
+	return spool.cleanup()
+}
+
+// Size returns number of bytes that wasn't committed yet
+func (spool *Spool) Size() int64 {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	return spool.size - spool.cursor.offset
+}
+
+// Close syncs and closes current segment file
+func (spool *Spool) Close() error {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	if spool.file == nil {
+		return nil
+	}
+	err := spool.file.Sync()
+	if e := spool.file.Close(); err == nil {
+		err = e
+	}
+	spool.file = nil
+	return err
+}
+
+// rotate closes current segment and starts new one
+func (spool *Spool) rotate() error {
+	if spool.file != nil {
+		if err := spool.file.Sync(); err != nil {
+			return err
+		}
+		if err := spool.file.Close(); err != nil {
+			return err
+		}
+		spool.file = nil
+	}
+
+	id := spool.cursor.segment + 1
+	if len(spool.segments) > 0 && spool.segments[len(spool.segments)-1] >= id {
+		id = spool.segments[len(spool.segments)-1] + 1
+	}
+	file, err := os.OpenFile(spool.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %v", err)
+	}
+	spool.file = file
+	spool.fileID = id
+	spool.segments = append(spool.segments, id)
+	spool.sizes[id] = 0
+	spool.lastSync = time.Now()
+	return nil
+}
+
+// cleanup removes segments that are fully read. Segment that we are writing
+// to is never removed
+func (spool *Spool) cleanup() error {
+	for len(spool.segments) > 0 {
+		id := spool.segments[0]
+		active := spool.file != nil && id == spool.fileID
+		consumed := id < spool.cursor.segment ||
+			id == spool.cursor.segment && spool.cursor.offset >= spool.sizes[id] && !active
+		if !consumed {
+			break
+		}
+
+		if err := os.Remove(spool.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove spool segment: %v", err)
+		}
+		spool.size -= spool.sizes[id]
+		delete(spool.sizes, id)
+		spool.segments = spool.segments[1:]
+		if id == spool.cursor.segment {
+			spool.cursor = spoolPosition{segment: id + 1}
+			spool.next = spool.cursor
+		}
+	}
+	return nil
+}
+
+func (spool *Spool) segmentPath(id int64) string {
+	return filepath.Join(spool.dir, fmt.Sprintf("%016d%s", id, segmentExt))
+}
+
+func (spool *Spool) loadCursor() error {
+	data, err := ioutil.ReadFile(filepath.Join(spool.dir, cursorFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read spool cursor: %v", err)
+	}
+	if _, err := fmt.Sscanf(string(data), "%d %d", &spool.cursor.segment, &spool.cursor.offset); err != nil {
+		return fmt.Errorf("failed to parse spool cursor: %v", err)
+	}
+	return nil
+}
+
+func (spool *Spool) saveCursor() error {
+	path := filepath.Join(spool.dir, cursorFile)
+	data := fmt.Sprintf("%d %d\n", spool.cursor.segment, spool.cursor.offset)
+	if err := ioutil.WriteFile(path+".tmp", []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %v", err)
+	}
+	return nil
+}
+
+// spill writes batch to client.Spool and returns number of spooled datapoints
+func (client *Client) spill(batch DataPoints) int {
+	if client.Spool == nil || len(batch) == 0 {
+		return 0
+	}
+	if err := client.Spool.Write(batch); err != nil {
+		return 0
+	}
+	atomic.AddInt64(&client.Spooled, int64(len(batch)))
+	return len(batch)
+}
+
+// overflow spools dp that didn't fit into Queue. If it can't be spooled, dp
+// is counted as Dropped and err is returned
+func (client *Client) overflow(dp *DataPoint, err error) error {
+	if client.spill(DataPoints{dp}) == 1 {
+		return nil
+	}
+	atomic.AddInt64(&client.Dropped, 1)
+	return err
+}
+
+// salvage spools part of failed batch that wasn't requeued or spooled yet,
+// and returns number of datapoints that are lost
+func (client *Client) salvage(batch DataPoints, err error) int {
+	if rejected, ok := err.(*RejectedError); ok {
+		return int(rejected.Failed)
+	}
+	if sendErr, ok := err.(*SendError); ok {
+		return sendErr.Lost()
+	}
+	return len(batch) - client.spill(batch)
+}
+
+// replay sends datapoints from client.Spool in order. While TSD is failing,
+// it only probes it once in Spool.ReplayInterval
+func (client *Client) replay(batchSize int) {
+	defer client.replaying.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-client.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	sender := client.senders()
+	if closer, ok := sender.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	interval := client.Spool.ReplayInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	wait := false
+	for {
+		if wait {
+			select {
+			case <-time.After(interval):
+			case <-client.done:
+				return
+			}
+		}
+
+		batch, err := client.Spool.Read(batchSize)
+		if err != nil {
+			client.report(err)
+			wait = true
+			continue
+		}
+		if len(batch) == 0 {
+			wait = true
+			continue
+		}
+
+		for _, part := range client.chunks(batch) {
+			if err = client.replayBatch(ctx, sender, part); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			client.report(fmt.Errorf("failed to replay spool: %v", err))
+			wait = true
+			continue
+		}
+		if err := client.Spool.Commit(); err != nil {
+			client.report(err)
+		}
+		wait = atomic.LoadInt32(&client.failing) != 0
+	}
+}
+
+// replayBatch sends batch from spool, splitting it if it's too large. Parts
+// that will never succeed, like rejected ones, are reported and dropped, so
+// they don't stall the spool. Only retryable error is returned, in that case
+// the whole batch should be replayed again
+func (client *Client) replayBatch(ctx context.Context, sender Sender, batch DataPoints) error {
+	err := client.post(ctx, sender, batch)
+	if tooLarge(err, batch) {
+		return client.split(ctx, sender, batch, client.replayBatch)
+	}
+	if err == nil || IsRetryable(err) || ctx.Err() != nil {
+		return err
+	}
+
+	if _, ok := err.(*RejectedError); !ok {
+		atomic.AddInt64(&client.Failed, int64(len(batch)))
+		err = fmt.Errorf("failed to replay spool, dropped %d datapoints: %v", len(batch), err)
+	}
+	client.report(err)
+	return nil
+}
+
+// chunks splits batch into parts that fit into client.MaxBatchBytes, the same
+// way worker does it
+func (client *Client) chunks(batch DataPoints) []DataPoints {
+	if client.MaxBatchBytes <= 0 {
+		return []DataPoints{batch}
+	}
+
+	var encoder jsonEncoder
+	var parts []DataPoints
+	start, size := 0, len("]\n")
+	for i, dp := range batch {
+		encoder.buf = encoder.buf[:0]
+		encoder.appendDataPoint(dp)
+		n := len(encoder.buf) + len(",")
+		if i > start && size+n > client.MaxBatchBytes {
+			parts = append(parts, batch[start:i])
+			start, size = i, len("]\n")
+		}
+		size += n
+	}
+	return append(parts, batch[start:])
+}
@@ -0,0 +1,211 @@
+package opentsdb
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolWriteReadCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, spool.Write(DataPoints{
+		&DataPoint{"test1", 123, 1, Tags{"key": "val"}},
+		&DataPoint{"test2", 124, 2, Tags{"key": "val"}},
+		&DataPoint{"test3", 125, 3, Tags{"key": "val"}},
+	}))
+
+	batch, err := spool.Read(2)
+	assert.NoError(t, err)
+	assert.Len(t, batch, 2)
+	assert.Equal(t, "test1", batch[0].Metric)
+
+	// without Commit we get the same datapoints
+	batch, err = spool.Read(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "test1", batch[0].Metric)
+	assert.NoError(t, spool.Commit())
+	assert.NoError(t, spool.Close())
+
+	// reopen and get what is left
+	spool, err = OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+	assert.True(t, spool.Size() > 0)
+
+	batch, err = spool.Read(10)
+	assert.NoError(t, err)
+	assert.Len(t, batch, 1)
+	assert.Equal(t, "test3", batch[0].Metric)
+	assert.Equal(t, "3", batch[0].Value.(fmt.Stringer).String())
+	assert.Equal(t, Tags{"key": "val"}, batch[0].Tags)
+	assert.NoError(t, spool.Commit())
+	assert.EqualValues(t, 0, spool.Size())
+
+	batch, err = spool.Read(10)
+	assert.NoError(t, err)
+	assert.Len(t, batch, 0)
+}
+
+func TestSpoolSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+	spool.MaxSegmentSize = 130
+	spool.Sync = SyncAlways
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, spool.Write(DataPoints{&DataPoint{"test1", int64(i), i, Tags{"key": "val"}}}))
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.Len(t, segments, 5)
+
+	batch, err := spool.Read(100)
+	assert.NoError(t, err)
+	assert.Len(t, batch, 10)
+	for i, dp := range batch {
+		assert.EqualValues(t, i, dp.Timestamp)
+	}
+	assert.NoError(t, spool.Commit())
+
+	// only segment that we are writing to is left
+	segments, _ = filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.Len(t, segments, 1)
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+	spool.MaxSize = 100
+
+	assert.NoError(t, spool.Write(dps[:1]))
+	assert.Equal(t, ErrSpoolFull, spool.Write(dps))
+
+	// space comes back after Commit
+	for i := 0; i < 50; i++ {
+		batch, err := spool.Read(10)
+		assert.NoError(t, err)
+		assert.Len(t, batch, 1)
+		assert.NoError(t, spool.Commit())
+		assert.Zero(t, spool.Size())
+		assert.NoError(t, spool.Write(dps[:1]))
+	}
+
+	// and fully committed segments are removed
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestClientWithSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+	spool.ReplayInterval = 10 * time.Millisecond
+
+	rec := &recorder{}
+	client := NewClientWithSenders(1, func() Sender { return rec })
+	client.Spool = spool
+
+	// Queue is full after first one, so rest will go to spool
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.Push(&DataPoint{"test1", 123, i, Tags{"key": "val"}}))
+	}
+	assert.EqualValues(t, 0, client.Dropped)
+	assert.EqualValues(t, 2, client.Spooled)
+
+	client.StartWorkers(1, 10, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, 3, rec.count())
+	assert.EqualValues(t, 3, atomic.LoadInt64(&client.Sent))
+	assert.EqualValues(t, 0, spool.Size())
+}
+
+func TestClientReplayWithPermanentErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spool, err := OpenSpool(dir)
+	assert.NoError(t, err)
+	defer spool.Close()
+	spool.ReplayInterval = 10 * time.Millisecond
+
+	batch := DataPoints{}
+	for i := 0; i < 4; i++ {
+		batch = append(batch, &DataPoint{"test1", 123, i, Tags{"key": "val"}})
+	}
+	batch[3].Metric = "bad"
+	assert.NoError(t, spool.Write(batch))
+
+	// TSD refuses more than 2 datapoints at once and fails on "bad" one
+	rec := &recorder{}
+	client := NewClientWithSenders(1, func() Sender {
+		return SenderFunc(func(ctx context.Context, batch DataPoints) error {
+			if len(batch) > 2 {
+				return &PutError{StatusCode: http.StatusRequestEntityTooLarge}
+			}
+			for _, dp := range batch {
+				if dp.Metric == "bad" {
+					return &PutError{StatusCode: http.StatusBadRequest}
+				}
+			}
+			return rec.Send(ctx, batch)
+		})
+	})
+	client.Spool = spool
+	client.StartWorkers(1, 10, time.Hour)
+	defer client.Close(context.Background())
+	time.Sleep(50 * time.Millisecond)
+
+	// 4 -> 2 + 2, and the second half with "bad" one is dropped
+	assert.Equal(t, 2, rec.count())
+	assert.EqualValues(t, 1, atomic.LoadInt64(&client.Splits))
+	assert.EqualValues(t, 2, atomic.LoadInt64(&client.Failed))
+	assert.EqualValues(t, 0, spool.Size())
+	assert.EqualError(t, <-client.Errors,
+		`failed to replay spool, dropped 2 datapoints: unexpected status 400 ("")`)
+}
+
+func TestClientChunks(t *testing.T) {
+	batch := DataPoints{}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, &DataPoint{"test1", 123, i, Tags{"key": "val"}})
+	}
+	client := NewClientWithSenders(1, nil)
+	assert.Equal(t, []DataPoints{batch}, client.chunks(batch))
+
+	// every datapoint is 66 bytes with separator
+	client.MaxBatchBytes = 150
+	assert.Equal(t, []DataPoints{batch[:2], batch[2:4], batch[4:]}, client.chunks(batch))
+
+	// datapoint that is too large on its own is still sent
+	client.MaxBatchBytes = 10
+	assert.Len(t, client.chunks(batch), 5)
+}
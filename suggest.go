@@ -0,0 +1,96 @@
+package opentsdb
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// SuggestType is kind of names that /api/suggest looks up
+type SuggestType string
+
+const (
+	// SuggestMetrics is for metric names
+	SuggestMetrics SuggestType = "metrics"
+	// SuggestTagKeys is for tag names
+	SuggestTagKeys SuggestType = "tagk"
+	// SuggestTagValues is for tag values
+	SuggestTagValues SuggestType = "tagv"
+)
+
+// DefaultSuggestMax is number of names returned by OpenTSDB if max is not set
+const DefaultSuggestMax = 25
+
+// suggestChars are ASCII characters allowed in names, sorted as OpenTSDB
+// sorts names
+const suggestChars = "-./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// Suggest returns at most max names of given type, that start with prefix,
+// in sorted order:
+// http://opentsdb.net/docs/build/html/api_http/suggest.html
+func (qc *QueryClient) Suggest(ctx context.Context, typ SuggestType, prefix string, max int) ([]string, error) {
+	params := url.Values{}
+	params.Set("type", string(typ))
+	if prefix != "" {
+		params.Set("q", prefix)
+	}
+	if max > 0 {
+		params.Set("max", strconv.Itoa(max))
+	}
+
+	var names []string
+	if err := qc.do(ctx, "GET", "api/suggest", params, nil, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// SuggestAll returns all names of given type that start with prefix, making
+// as many Suggest requests of max names as needed. When page is full, names
+// after its last one are requested by narrowing prefix with every next
+// character. Only ASCII characters are used for narrowing, so names with
+// other unicode letters right after full page could be missed
+func (qc *QueryClient) SuggestAll(ctx context.Context, typ SuggestType, prefix string, max int) ([]string, error) {
+	if max <= 0 {
+		max = DefaultSuggestMax
+	}
+
+	var names []string
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		page, err := qc.Suggest(ctx, typ, prefix, max)
+		if err != nil {
+			return err
+		}
+		// pages are walked in sorted order, so anything not greater than
+		// the last name is already collected
+		for _, name := range page {
+			if len(names) == 0 || name > names[len(names)-1] {
+				names = append(names, name)
+			}
+		}
+		if len(page) < max {
+			return nil
+		}
+
+		// every name before the last one in page is already there
+		var next byte
+		if last := page[len(page)-1]; len(last) > len(prefix) {
+			next = last[len(prefix)]
+		}
+		for i := 0; i < len(suggestChars); i++ {
+			if suggestChars[i] < next {
+				continue
+			}
+			if err := walk(prefix + suggestChars[i:i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(prefix); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
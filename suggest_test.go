@@ -0,0 +1,84 @@
+package opentsdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createSuggestServer emulates /api/suggest over given names
+func createSuggestServer(t *testing.T, names []string, requests *int) *httptest.Server {
+	sort.Strings(names)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		assert.Equal(t, "/api/suggest", r.URL.Path)
+		assert.Equal(t, "metrics", r.URL.Query().Get("type"))
+		prefix := r.URL.Query().Get("q")
+		max, err := strconv.Atoi(r.URL.Query().Get("max"))
+		assert.NoError(t, err)
+
+		result := []string{}
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) && len(result) < max {
+				result = append(result, name)
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestSuggest(t *testing.T) {
+	requests := 0
+	ts := createSuggestServer(t, []string{"sys.cpu.user", "sys.cpu.system", "sys.mem", "app.requests"}, &requests)
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	names, err := qc.Suggest(context.Background(), SuggestMetrics, "sys.cpu", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sys.cpu.system", "sys.cpu.user"}, names)
+}
+
+func TestSuggestAll(t *testing.T) {
+	var all []string
+	for _, host := range []string{"a", "b", "c"} {
+		for i := 0; i < 7; i++ {
+			all = append(all, "sys."+host+"."+strconv.Itoa(i))
+		}
+	}
+	all = append(all, "sys", "sys.a", "sys.c.0.x", "sys_x", "app.requests")
+
+	requests := 0
+	ts := createSuggestServer(t, all, &requests)
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	names, err := qc.SuggestAll(context.Background(), SuggestMetrics, "sys", 5)
+	assert.NoError(t, err)
+
+	var expected []string
+	for _, name := range all {
+		if strings.HasPrefix(name, "sys") {
+			expected = append(expected, name)
+		}
+	}
+	sort.Strings(expected)
+	assert.Equal(t, expected, names)
+	assert.True(t, requests > 1)
+
+	// small result is returned with one request
+	requests = 0
+	names, err = qc.SuggestAll(context.Background(), SuggestMetrics, "app", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app.requests"}, names)
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,138 @@
+package opentsdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Telnet is client for OpenTSDB telnet-style "put" protocol:
+// http://opentsdb.net/docs/build/html/api_telnet/put.html
+// It keeps persistent connection to TSD and reconnects if it's broken.
+// TSD answers only with errors, and they are passed to errors channel
+type Telnet struct {
+	// Addr is host:port of TSD that is used by Send
+	Addr string
+
+	timeout time.Duration
+	errors  chan<- error
+	conn    *telnetConn
+}
+
+// TelnetError is error line that TSD sent back to Telnet
+type TelnetError struct {
+	Addr    string
+	Message string
+}
+
+func (err *TelnetError) Error() string {
+	return fmt.Sprintf("tsd %s: %s", err.Addr, err.Message)
+}
+
+// Retryable is always false, TSD will reject the same line again
+func (err *TelnetError) Retryable() bool {
+	return false
+}
+
+type telnetConn struct {
+	addr   string
+	conn   net.Conn
+	writer *bufio.Writer
+	done   chan struct{}
+	broken int32
+}
+
+// NewTelnet creates Telnet with given timeout for connect and write.
+// Errors from TSD will be sent to errors, if it's not nil
+func NewTelnet(timeout time.Duration, errors chan<- error) *Telnet {
+	return &Telnet{timeout: timeout, errors: errors}
+}
+
+// Post will write batch as "put" lines to TSD at given addr, connecting to it
+// if there is no connection yet or previous one was broken
+func (telnet *Telnet) Post(batch DataPoints, addr string) error {
+	return telnet.post(context.Background(), batch, addr)
+}
+
+// Send will write batch to TSD at telnet.Addr, it implements Sender
+func (telnet *Telnet) Send(ctx context.Context, batch DataPoints) error {
+	return telnet.post(ctx, batch, telnet.Addr)
+}
+
+func (telnet *Telnet) post(ctx context.Context, batch DataPoints, addr string) error {
+	c := telnet.conn
+	if c == nil || c.addr != addr || atomic.LoadInt32(&c.broken) != 0 {
+		var err error
+		if c, err = telnet.connect(ctx, addr); err != nil {
+			return err
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if telnet.timeout > 0 && (!ok || time.Until(deadline) > telnet.timeout) {
+		deadline = time.Now().Add(telnet.timeout)
+	}
+	c.conn.SetWriteDeadline(deadline)
+	for _, dp := range batch {
+		c.writer.WriteString("put ")
+		c.writer.WriteString(dp.String())
+		c.writer.WriteByte('\n')
+	}
+	if err := c.writer.Flush(); err != nil {
+		telnet.Close()
+		return err
+	}
+	return nil
+}
+
+// Close closes current connection, if any
+func (telnet *Telnet) Close() error {
+	c := telnet.conn
+	if c == nil {
+		return nil
+	}
+	telnet.conn = nil
+	close(c.done)
+	return c.conn.Close()
+}
+
+func (telnet *Telnet) connect(ctx context.Context, addr string) (*telnetConn, error) {
+	telnet.Close()
+
+	dialer := &net.Dialer{Timeout: telnet.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &telnetConn{
+		addr:   addr,
+		conn:   conn,
+		writer: bufio.NewWriter(conn),
+		done:   make(chan struct{}),
+	}
+	telnet.conn = c
+	go telnet.read(c)
+	return c, nil
+}
+
+// read passes error lines from TSD to telnet.errors until connection is
+// closed, after that connection is marked as broken
+func (telnet *Telnet) read(c *telnetConn) {
+	defer atomic.StoreInt32(&c.broken, 1)
+
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || telnet.errors == nil {
+			continue
+		}
+		select {
+		case telnet.errors <- &TelnetError{Addr: c.addr, Message: line}:
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package opentsdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelnetPost(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+			fmt.Fprintf(conn, "put: illegal argument: bad value\n")
+		}
+	}()
+
+	errors := make(chan error, 10)
+	telnet := NewTelnet(time.Second, errors)
+	defer telnet.Close()
+
+	batch := DataPoints{
+		&DataPoint{"test1", 123, 1.5, Tags{"key_z": "val1", "key_a": "val2"}},
+	}
+	assert.NoError(t, telnet.Post(batch, ln.Addr().String()))
+	assert.Equal(t, "put test1 123 1.5 key_a=val2 key_z=val1", <-lines)
+
+	select {
+	case err := <-errors:
+		assert.EqualError(t, err, "tsd "+ln.Addr().String()+": put: illegal argument: bad value")
+		assert.False(t, IsRetryable(err))
+	case <-time.After(time.Second):
+		t.Fatal("expected error from tsd")
+	}
+}
+
+func TestTelnetReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// read one line and drop connection
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			lines <- line
+			conn.Close()
+		}
+	}()
+
+	telnet := NewTelnet(time.Second, nil)
+	defer telnet.Close()
+
+	batch := DataPoints{&DataPoint{"test1", 123, 1.0, Tags{"key": "val"}}}
+	assert.NoError(t, telnet.Post(batch, ln.Addr().String()))
+	assert.Equal(t, "put test1 123 1.0 key=val\n", <-lines)
+
+	// wait until reader notices that connection is closed
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, telnet.Post(batch, ln.Addr().String()))
+	assert.Equal(t, "put test1 123 1.0 key=val\n", <-lines)
+}
+
+func TestTelnetClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	client, err := NewTelnetClient(ln.Addr().String(), 10, time.Second)
+	assert.NoError(t, err)
+	client.StartWorkers(1, 10, time.Hour)
+
+	assert.NoError(t, client.Push(&DataPoint{"test1", 123, 2.0, Tags{"key": "val"}}))
+	assert.NoError(t, client.Flush(context.Background()))
+	assert.Equal(t, "put test1 123 2.0 key=val", <-lines)
+	assert.EqualValues(t, 1, client.Sent)
+}
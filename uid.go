@@ -0,0 +1,240 @@
+package opentsdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UIDType is kind of name that has UID in OpenTSDB
+type UIDType string
+
+const (
+	// UIDMetric is for metric names
+	UIDMetric UIDType = "metric"
+	// UIDTagKey is for tag names
+	UIDTagKey UIDType = "tagk"
+	// UIDTagValue is for tag values
+	UIDTagValue UIDType = "tagv"
+)
+
+// UnmarshalJSON implements json.Unmarshaler, OpenTSDB sends types in upper
+// case, e.g. METRIC
+func (typ *UIDType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*typ = UIDType(strings.ToLower(s))
+	return nil
+}
+
+// uidTypes are all UID types in order they're reported by OpenTSDB
+var uidTypes = []UIDType{UIDMetric, UIDTagKey, UIDTagValue}
+
+// existsPrefix starts error message for name that already has UID
+const existsPrefix = "Name already exists with UID: "
+
+// UIDAssignment is body of /api/uid/assign request:
+// http://opentsdb.net/docs/build/html/api_http/uid/assign.html
+type UIDAssignment struct {
+	Metrics   []string `json:"metric,omitempty"`
+	TagKeys   []string `json:"tagk,omitempty"`
+	TagValues []string `json:"tagv,omitempty"`
+}
+
+// AssignResult maps names to UIDs by UIDType. Names that got new UID are in
+// Assigned, names that already had one are in Existing, and for the rest
+// error message from OpenTSDB is in Failed
+type AssignResult struct {
+	Assigned map[UIDType]map[string]string
+	Existing map[UIDType]map[string]string
+	Failed   map[UIDType]map[string]string
+}
+
+// AssignUIDs assigns UIDs to all names of req, e.g. to create metrics when
+// tsd.core.auto_create_metrics is off. Names that already had UID are not
+// errors, they're reported in Existing with their UIDs. Other failures are
+// reported in Failed, while error is returned only if request itself failed
+func (qc *QueryClient) AssignUIDs(ctx context.Context, req *UIDAssignment) (*AssignResult, error) {
+	var resp map[string]map[string]string
+	err := qc.do(ctx, "POST", "api/uid/assign", nil, req, &resp)
+
+	// OpenTSDB responds with 400 if any name failed, but body has results
+	// for all names anyway
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest &&
+		json.Unmarshal([]byte(apiErr.Body), &resp) == nil && resp != nil {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AssignResult{
+		Assigned: make(map[UIDType]map[string]string),
+		Existing: make(map[UIDType]map[string]string),
+		Failed:   make(map[UIDType]map[string]string),
+	}
+	add := func(m map[UIDType]map[string]string, typ UIDType, name, value string) {
+		if m[typ] == nil {
+			m[typ] = make(map[string]string)
+		}
+		m[typ][name] = value
+	}
+	for _, typ := range uidTypes {
+		for name, uid := range resp[string(typ)] {
+			add(result.Assigned, typ, name, uid)
+		}
+		for name, msg := range resp[string(typ)+"_errors"] {
+			if strings.HasPrefix(msg, existsPrefix) {
+				add(result.Existing, typ, name, strings.TrimPrefix(msg, existsPrefix))
+			} else {
+				add(result.Failed, typ, name, msg)
+			}
+		}
+	}
+	return result, nil
+}
+
+// UIDMeta is meta data of metric, tag name or tag value:
+// http://opentsdb.net/docs/build/html/api_http/uid/uidmeta.html
+type UIDMeta struct {
+	UID  string  `json:"uid"`
+	Type UIDType `json:"type"`
+	// Name and Created are set by OpenTSDB and ignored on update
+	Name        string            `json:"name,omitempty"`
+	Created     int64             `json:"created,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Notes       string            `json:"notes,omitempty"`
+	Custom      map[string]string `json:"custom,omitempty"`
+}
+
+// UIDMeta returns meta data for uid of given type
+func (qc *QueryClient) UIDMeta(ctx context.Context, typ UIDType, uid string) (*UIDMeta, error) {
+	params := url.Values{}
+	params.Set("type", string(typ))
+	params.Set("uid", uid)
+
+	meta := &UIDMeta{}
+	if err := qc.do(ctx, "GET", "api/uid/uidmeta", params, nil, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// UpdateUIDMeta changes fields of meta that are not empty and returns
+// updated meta data. UID and Type are required
+func (qc *QueryClient) UpdateUIDMeta(ctx context.Context, meta *UIDMeta) (*UIDMeta, error) {
+	body := &UIDMeta{
+		UID:         meta.UID,
+		Type:        meta.Type,
+		DisplayName: meta.DisplayName,
+		Description: meta.Description,
+		Notes:       meta.Notes,
+		Custom:      meta.Custom,
+	}
+	updated := &UIDMeta{}
+	err := qc.do(ctx, "POST", "api/uid/uidmeta", nil, body, updated)
+	if isNotModified(err) {
+		return qc.UIDMeta(ctx, meta.Type, meta.UID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// TSMeta is meta data of time series:
+// http://opentsdb.net/docs/build/html/api_http/uid/tsmeta.html
+type TSMeta struct {
+	TSUID string `json:"tsuid"`
+	// Metric, Tags, Created, LastReceived and TotalDatapoints are set by
+	// OpenTSDB and ignored on update
+	Metric          *UIDMeta          `json:"metric,omitempty"`
+	Tags            []*UIDMeta        `json:"tags,omitempty"`
+	Created         int64             `json:"created,omitempty"`
+	LastReceived    int64             `json:"lastReceived,omitempty"`
+	TotalDatapoints int64             `json:"totalDatapoints,omitempty"`
+	DisplayName     string            `json:"displayName,omitempty"`
+	Description     string            `json:"description,omitempty"`
+	Notes           string            `json:"notes,omitempty"`
+	Units           string            `json:"units,omitempty"`
+	DataType        string            `json:"dataType,omitempty"`
+	Retention       int               `json:"retention,omitempty"`
+	Custom          map[string]string `json:"custom,omitempty"`
+	// Max and Min are NaN unless they were set
+	Max Value `json:"max"`
+	Min Value `json:"min"`
+}
+
+// TSMeta returns meta data of time series with given tsuid
+func (qc *QueryClient) TSMeta(ctx context.Context, tsuid string) (*TSMeta, error) {
+	params := url.Values{}
+	params.Set("tsuid", tsuid)
+
+	meta := &TSMeta{}
+	if err := qc.do(ctx, "GET", "api/uid/tsmeta", params, nil, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// FindTSMeta returns meta data of all time series of metric with given tags
+func (qc *QueryClient) FindTSMeta(ctx context.Context, metric string, tags Tags) ([]*TSMeta, error) {
+	m := metric
+	if len(tags) > 0 {
+		m += tags.String()
+	}
+	params := url.Values{}
+	params.Set("m", m)
+
+	var metas []*TSMeta
+	if err := qc.do(ctx, "GET", "api/uid/tsmeta", params, nil, &metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+// UpdateTSMeta changes fields of meta that are not empty and returns updated
+// meta data. TSUID is required
+func (qc *QueryClient) UpdateTSMeta(ctx context.Context, meta *TSMeta) (*TSMeta, error) {
+	body := struct {
+		TSUID       string            `json:"tsuid"`
+		DisplayName string            `json:"displayName,omitempty"`
+		Description string            `json:"description,omitempty"`
+		Notes       string            `json:"notes,omitempty"`
+		Units       string            `json:"units,omitempty"`
+		DataType    string            `json:"dataType,omitempty"`
+		Retention   int               `json:"retention,omitempty"`
+		Custom      map[string]string `json:"custom,omitempty"`
+	}{
+		TSUID:       meta.TSUID,
+		DisplayName: meta.DisplayName,
+		Description: meta.Description,
+		Notes:       meta.Notes,
+		Units:       meta.Units,
+		DataType:    meta.DataType,
+		Retention:   meta.Retention,
+		Custom:      meta.Custom,
+	}
+	updated := &TSMeta{}
+	err := qc.do(ctx, "POST", "api/uid/tsmeta", nil, body, updated)
+	if isNotModified(err) {
+		return qc.TSMeta(ctx, meta.TSUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// isNotModified reports whether err is 304 response, that OpenTSDB returns
+// when update didn't change anything
+func isNotModified(err error) bool {
+	return errors.Is(err, &APIError{StatusCode: http.StatusNotModified})
+}
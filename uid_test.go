@@ -0,0 +1,135 @@
+package opentsdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignUIDs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/uid/assign", r.URL.Path)
+		var req map[string][]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []string{"sys.cpu.0", "sys.cpu.1"}, req["metric"])
+		assert.Equal(t, []string{"host"}, req["tagk"])
+		assert.NotContains(t, req, "tagv")
+
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"metric":{"sys.cpu.0":"000042"},`+
+			`"metric_errors":{"sys.cpu.1":"Name already exists with UID: 000002"},`+
+			`"tagk":{},"tagk_errors":{"host":"Invalid name"}}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	result, err := qc.AssignUIDs(context.Background(), &UIDAssignment{
+		Metrics: []string{"sys.cpu.0", "sys.cpu.1"},
+		TagKeys: []string{"host"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[UIDType]map[string]string{UIDMetric: {"sys.cpu.0": "000042"}}, result.Assigned)
+	assert.Equal(t, map[UIDType]map[string]string{UIDMetric: {"sys.cpu.1": "000002"}}, result.Existing)
+	assert.Equal(t, map[UIDType]map[string]string{UIDTagKey: {"host": "Invalid name"}}, result.Failed)
+}
+
+func TestAssignUIDsWithError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":400,"message":"Missing values to assign UIDs"}}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	_, err = qc.AssignUIDs(context.Background(), &UIDAssignment{})
+	assert.IsType(t, &APIError{}, err)
+	assert.Equal(t, "Missing values to assign UIDs", err.(*APIError).Message)
+}
+
+func TestUIDMeta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/uid/uidmeta", r.URL.Path)
+		switch r.Method {
+		case "GET":
+			assert.Equal(t, "metric", r.URL.Query().Get("type"))
+			assert.Equal(t, "00002A", r.URL.Query().Get("uid"))
+		case "POST":
+			var req map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, map[string]interface{}{
+				"uid": "00002A", "type": "metric", "description": "CPU usage",
+			}, req)
+		}
+		fmt.Fprint(w, `{"uid":"00002A","type":"METRIC","name":"sys.cpu.0","description":"CPU usage",`+
+			`"notes":"","created":1350425579,"custom":{"owner":"ops"},"displayName":""}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	meta, err := qc.UIDMeta(context.Background(), UIDMetric, "00002A")
+	assert.NoError(t, err)
+	assert.Equal(t, "sys.cpu.0", meta.Name)
+	assert.Equal(t, UIDMetric, meta.Type)
+	assert.EqualValues(t, 1350425579, meta.Created)
+	assert.Equal(t, map[string]string{"owner": "ops"}, meta.Custom)
+
+	meta, err = qc.UpdateUIDMeta(context.Background(), &UIDMeta{
+		UID: "00002A", Type: UIDMetric, Name: "ignored", Description: "CPU usage",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "CPU usage", meta.Description)
+}
+
+func TestUpdateTSMetaNotModified(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/uid/tsmeta", r.URL.Path)
+		if r.Method == "POST" {
+			var req map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, map[string]interface{}{"tsuid": "000001000001000001", "units": "%"}, req)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		assert.Equal(t, "000001000001000001", r.URL.Query().Get("tsuid"))
+		fmt.Fprint(w, `{"tsuid":"000001000001000001","metric":{"uid":"000001","type":"METRIC","name":"sys.cpu.0"},`+
+			`"tags":[{"uid":"000001","type":"TAGK","name":"host"},{"uid":"000001","type":"TAGV","name":"web01"}],`+
+			`"units":"%","retention":0,"max":"NaN","min":"NaN","lastReceived":1350425579,"totalDatapoints":42}`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	meta, err := qc.UpdateTSMeta(context.Background(), &TSMeta{TSUID: "000001000001000001", Units: "%"})
+	assert.NoError(t, err)
+	assert.Equal(t, "sys.cpu.0", meta.Metric.Name)
+	assert.Len(t, meta.Tags, 2)
+	assert.Equal(t, UIDTagValue, meta.Tags[1].Type)
+	assert.Equal(t, "%", meta.Units)
+	assert.EqualValues(t, 42, meta.TotalDatapoints)
+	assert.True(t, math.IsNaN(meta.Max.Float64()))
+}
+
+func TestFindTSMeta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "sys.cpu.0{host=web01}", r.URL.Query().Get("m"))
+		fmt.Fprint(w, `[{"tsuid":"000001000001000001"},{"tsuid":"000001000001000002"}]`)
+	}))
+	defer ts.Close()
+
+	qc, err := NewQueryClient(ts.URL, nil, time.Second)
+	assert.NoError(t, err)
+	metas, err := qc.FindTSMeta(context.Background(), "sys.cpu.0", Tags{"host": "web01"})
+	assert.NoError(t, err)
+	assert.Len(t, metas, 2)
+	assert.Equal(t, "000001000001000002", metas[1].TSUID)
+}
@@ -0,0 +1,192 @@
+package opentsdb
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"unicode"
+)
+
+// DefaultMaxTags is default limit of tags per datapoint in OpenTSDB, see
+// tsd.storage.max_tags
+const DefaultMaxTags = 8
+
+// ValidationReason is why DataPoint is invalid
+type ValidationReason int
+
+const (
+	// EmptyMetric is for datapoint without metric name
+	EmptyMetric ValidationReason = iota
+	// InvalidMetric is for metric name with characters OpenTSDB doesn't allow
+	InvalidMetric
+	// NoTags is for datapoint without tags, OpenTSDB requires at least one
+	NoTags
+	// TooManyTags is for datapoint with more tags than allowed
+	TooManyTags
+	// InvalidTag is for tag with empty or invalid key or value
+	InvalidTag
+	// InvalidValue is for value that is not a number
+	InvalidValue
+	// NonFiniteValue is for NaN and infinite values
+	NonFiniteValue
+	// NegativeTimestamp is for timestamps before unix epoch
+	NegativeTimestamp
+
+	numReasons
+)
+
+var reasonNames = [numReasons]string{
+	"empty metric",
+	"invalid metric",
+	"no tags",
+	"too many tags",
+	"invalid tag",
+	"invalid value",
+	"non-finite value",
+	"negative timestamp",
+}
+
+func (reason ValidationReason) String() string {
+	if reason < 0 || reason >= numReasons {
+		return fmt.Sprintf("reason(%d)", int(reason))
+	}
+	return reasonNames[reason]
+}
+
+// ValidationPolicy defines what Push does with invalid datapoints
+type ValidationPolicy int
+
+const (
+	// NoValidation pushes datapoints as is, it's the default
+	NoValidation ValidationPolicy = iota
+	// RejectInvalid returns *ValidationError from Push for invalid datapoint
+	RejectInvalid
+	// SanitizeInvalid replaces invalid characters in metric and tags and
+	// parses numeric strings, datapoints that still are invalid are rejected
+	SanitizeInvalid
+)
+
+// ValidationError describes why DataPoint would be rejected by OpenTSDB
+type ValidationError struct {
+	Reason ValidationReason
+	// Field is "metric", "timestamp", "value" or "tags", or tag key for
+	// InvalidTag
+	Field   string
+	Message string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid datapoint: %s: %s", err.Reason, err.Message)
+}
+
+// Is reports whether target is *ValidationError with the same Reason, so
+// errors.Is(err, &ValidationError{Reason: NoTags}) works
+func (err *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && t.Reason == err.Reason
+}
+
+// Retryable is always false, the same datapoint will be rejected again
+func (err *ValidationError) Retryable() bool {
+	return false
+}
+
+// Validate checks dp against OpenTSDB rules for metric, tags, value and
+// timestamp with DefaultMaxTags limit
+func (dp *DataPoint) Validate() error {
+	return dp.validate(DefaultMaxTags)
+}
+
+func (dp *DataPoint) validate(maxTags int) error {
+	switch {
+	case dp.Metric == "":
+		return &ValidationError{EmptyMetric, "metric", "metric name is empty"}
+	case !validName(dp.Metric):
+		return &ValidationError{InvalidMetric, "metric", fmt.Sprintf("metric name %q has invalid characters", dp.Metric)}
+	case dp.Timestamp < 0:
+		return &ValidationError{NegativeTimestamp, "timestamp", fmt.Sprintf("timestamp %d is negative", dp.Timestamp)}
+	case len(dp.Tags) == 0:
+		return &ValidationError{NoTags, "tags", "at least one tag is required"}
+	case len(dp.Tags) > maxTags:
+		return &ValidationError{TooManyTags, "tags", fmt.Sprintf("%d tags, at most %d allowed", len(dp.Tags), maxTags)}
+	}
+	for key, value := range dp.Tags {
+		if key == "" || value == "" || !validName(key) || !validName(value) {
+			return &ValidationError{InvalidTag, key, fmt.Sprintf("tag %q=%q is invalid", key, value)}
+		}
+	}
+
+	value, err := ParseValue(dp.Value)
+	if err != nil {
+		return &ValidationError{InvalidValue, "value", err.Error()}
+	}
+	if f := value.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+		return &ValidationError{NonFiniteValue, "value", fmt.Sprintf("value %v is not finite", f)}
+	}
+	return nil
+}
+
+// sanitize returns copy of dp with invalid characters in metric and tags
+// replaced and numeric string value parsed, tags that became empty are
+// dropped
+func (dp *DataPoint) sanitize() *DataPoint {
+	fixed := &DataPoint{
+		Metric:    MustReplace(dp.Metric, "_"),
+		Timestamp: dp.Timestamp,
+		Value:     dp.Value,
+		Tags:      make(Tags, len(dp.Tags)),
+	}
+	for key, value := range dp.Tags {
+		fixed.Tags.Set(key, value)
+	}
+	if s, ok := dp.Value.(string); ok {
+		if value, err := ParseValue(s); err == nil {
+			fixed.Value = value
+		}
+	}
+	return fixed
+}
+
+// validName checks that s has only characters allowed by OpenTSDB
+func validName(s string) bool {
+	for _, r := range s {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' || r == '/') {
+			return false
+		}
+	}
+	return true
+}
+
+// Invalid returns number of datapoints that Push rejected for given reason
+func (client *Client) Invalid(reason ValidationReason) int64 {
+	if reason < 0 || reason >= numReasons {
+		return 0
+	}
+	return atomic.LoadInt64(&client.invalid[reason])
+}
+
+// validate checks dp according to client.Validation, it returns datapoint
+// that should be pushed, which is sanitized copy of dp for SanitizeInvalid
+func (client *Client) validate(dp *DataPoint) (*DataPoint, error) {
+	if client.Validation == NoValidation {
+		return dp, nil
+	}
+	maxTags := client.MaxTags
+	if maxTags <= 0 {
+		maxTags = DefaultMaxTags
+	}
+
+	err := dp.validate(maxTags)
+	if err != nil && client.Validation == SanitizeInvalid {
+		fixed := dp.sanitize()
+		if err = fixed.validate(maxTags); err == nil {
+			atomic.AddInt64(&client.Sanitized, 1)
+			return fixed, nil
+		}
+	}
+	if err != nil {
+		atomic.AddInt64(&client.invalid[err.(*ValidationError).Reason], 1)
+		return nil, err
+	}
+	return dp, nil
+}
@@ -0,0 +1,89 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataPointValidate(t *testing.T) {
+	tags := Tags{"host": "web01"}
+	tests := []struct {
+		dp     *DataPoint
+		reason ValidationReason
+	}{
+		{&DataPoint{"", 1356998400, 1, tags}, EmptyMetric},
+		{&DataPoint{"sys cpu", 1356998400, 1, tags}, InvalidMetric},
+		{&DataPoint{"sys.cpu", -1, 1, tags}, NegativeTimestamp},
+		{&DataPoint{"sys.cpu", 1356998400, 1, nil}, NoTags},
+		{&DataPoint{"sys.cpu", 1356998400, 1, Tags{
+			"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6", "g": "7", "h": "8", "i": "9",
+		}}, TooManyTags},
+		{&DataPoint{"sys.cpu", 1356998400, 1, Tags{"host": ""}}, InvalidTag},
+		{&DataPoint{"sys.cpu", 1356998400, 1, Tags{"host": "web 01"}}, InvalidTag},
+		{&DataPoint{"sys.cpu", 1356998400, "high", tags}, InvalidValue},
+		{&DataPoint{"sys.cpu", 1356998400, nil, tags}, InvalidValue},
+		{&DataPoint{"sys.cpu", 1356998400, math.NaN(), tags}, NonFiniteValue},
+		{&DataPoint{"sys.cpu", 1356998400, math.Inf(-1), tags}, NonFiniteValue},
+	}
+	for _, test := range tests {
+		err := test.dp.Validate()
+		if assert.Error(t, err, "%v", test.dp) {
+			assert.True(t, errors.Is(err, &ValidationError{Reason: test.reason}), "%v", err)
+			assert.False(t, IsRetryable(err))
+		}
+	}
+
+	for _, value := range []interface{}{1, int64(-5), uint8(1), float32(1.5), 0.1, "42.5", json.Number("7")} {
+		dp := &DataPoint{"sys.cpu/user-time_1", 1356998400000, value, Tags{"host": "web-01.dc/1"}}
+		assert.NoError(t, dp.Validate(), "%#v", value)
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := (&DataPoint{"sys.cpu", 1356998400, 1, nil}).Validate()
+	assert.EqualError(t, err, "invalid datapoint: no tags: at least one tag is required")
+	assert.Equal(t, "tags", err.(*ValidationError).Field)
+}
+
+func TestPushWithRejectInvalid(t *testing.T) {
+	client := NewClientWithSenders(10, nil)
+	client.Validation = RejectInvalid
+	client.MaxTags = 1
+
+	assert.NoError(t, client.Push(&DataPoint{"test1", 1356998400, 1, Tags{"a": "b"}}))
+	assert.Error(t, client.Push(&DataPoint{"test1", 1356998400, 1, Tags{"a": "b", "c": "d"}}))
+	assert.Error(t, client.Push(&DataPoint{"test1", 1356998400, math.NaN(), Tags{"a": "b"}}))
+	assert.Error(t, client.Push(&DataPoint{"test1", 1356998400, math.NaN(), Tags{"a": "b"}}))
+	assert.Error(t, client.Push(&DataPoint{"test 1", 1356998400, 1, Tags{"a": "b"}}))
+
+	assert.Len(t, client.Queue, 1)
+	assert.Equal(t, int64(1), client.Invalid(TooManyTags))
+	assert.Equal(t, int64(2), client.Invalid(NonFiniteValue))
+	assert.Equal(t, int64(1), client.Invalid(InvalidMetric))
+	assert.Equal(t, int64(0), client.Invalid(NoTags))
+	assert.Equal(t, int64(0), client.Sanitized)
+}
+
+func TestPushWithSanitizeInvalid(t *testing.T) {
+	client := NewClientWithSenders(10, nil)
+	client.Validation = SanitizeInvalid
+
+	dp := &DataPoint{"sys cpu", 1356998400, "0.5", Tags{"host": "web 01", "bad": "!"}}
+	assert.NoError(t, client.Push(dp))
+	assert.Error(t, client.Push(&DataPoint{"test1", 1356998400, 1, Tags{}}))
+
+	assert.Len(t, client.Queue, 1)
+	pushed := <-client.Queue
+	assert.Equal(t, "sys_cpu", pushed.Metric)
+	assert.Equal(t, Float(0.5), pushed.Value)
+	assert.Equal(t, Tags{"host": "web_01", "bad": "_"}, pushed.Tags)
+	// original datapoint is left as is
+	assert.Equal(t, "sys cpu", dp.Metric)
+
+	assert.Equal(t, int64(1), client.Sanitized)
+	assert.Equal(t, int64(1), client.Invalid(NoTags))
+}
@@ -0,0 +1,181 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value is numeric value of DataPoint, that keeps whether it's integer or
+// floating point, so int64 counters don't lose precision and OpenTSDB
+// stores them with the right type. Zero Value is integer zero
+type Value struct {
+	i       int64
+	f       float64
+	isFloat bool
+}
+
+// Int creates integer Value
+func Int(v int64) Value {
+	return Value{i: v}
+}
+
+// Float creates floating point Value
+func Float(v float64) Value {
+	return Value{f: v, isFloat: true}
+}
+
+// ParseValue creates Value from any Go number, json.Number or numeric string.
+// Integers stay integers, everything else is rejected with error
+func ParseValue(v interface{}) (Value, error) {
+	switch v := v.(type) {
+	case Value:
+		return v, nil
+	case int:
+		return Int(int64(v)), nil
+	case int8:
+		return Int(int64(v)), nil
+	case int16:
+		return Int(int64(v)), nil
+	case int32:
+		return Int(int64(v)), nil
+	case int64:
+		return Int(v), nil
+	case uint:
+		return parseUint(uint64(v))
+	case uint8:
+		return Int(int64(v)), nil
+	case uint16:
+		return Int(int64(v)), nil
+	case uint32:
+		return Int(int64(v)), nil
+	case uint64:
+		return parseUint(v)
+	case float32:
+		// shortest float32 representation keeps 0.1 from becoming 0.10000000149
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return Float(f), nil
+	case float64:
+		return Float(v), nil
+	case json.Number:
+		return parseNumber(string(v))
+	case string:
+		return parseNumber(v)
+	}
+	return Value{}, fmt.Errorf("value %#v is not a number", v)
+}
+
+func parseUint(v uint64) (Value, error) {
+	if v > math.MaxInt64 {
+		return Value{}, fmt.Errorf("value %d overflows int64", v)
+	}
+	return Int(int64(v)), nil
+}
+
+// parseNumber parses s the way OpenTSDB does: it's integer unless it has
+// decimal point or exponent
+func parseNumber(s string) (Value, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return Int(i), nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("value %q is not a number", s)
+	}
+	return Float(f), nil
+}
+
+// IsFloat reports whether v is floating point
+func (v Value) IsFloat() bool {
+	return v.isFloat
+}
+
+// Int64 returns v as int64, floating point value is truncated
+func (v Value) Int64() int64 {
+	if v.isFloat {
+		return int64(v.f)
+	}
+	return v.i
+}
+
+// Float64 returns v as float64
+func (v Value) Float64() float64 {
+	if v.isFloat {
+		return v.f
+	}
+	return float64(v.i)
+}
+
+// String formats integer without decimal point and float with the shortest
+// representation that round-trips, but always with decimal point or exponent
+func (v Value) String() string {
+	return string(v.append(nil))
+}
+
+// append appends formatted v to buf
+func (v Value) append(buf []byte) []byte {
+	if !v.isFloat {
+		return strconv.AppendInt(buf, v.i, 10)
+	}
+	return appendFloat(buf, v.f, 64)
+}
+
+// MarshalJSON implements json.Marshaler
+func (v Value) MarshalJSON() ([]byte, error) {
+	if v.isFloat && (math.IsNaN(v.f) || math.IsInf(v.f, 0)) {
+		return nil, fmt.Errorf("value %v is not finite", v.f)
+	}
+	return v.append(nil), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it accepts numbers and numeric
+// strings
+func (v *Value) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	value, err := parseNumber(s)
+	if err != nil {
+		return err
+	}
+	*v = value
+	return nil
+}
+
+// appendFloat appends f to buf, so that OpenTSDB won't mistake it for integer
+func appendFloat(buf []byte, f float64, bitSize int) []byte {
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, 'g', -1, bitSize)
+	for _, c := range buf[start:] {
+		if c == '.' || c == 'e' || c == 'N' || c == 'I' {
+			return buf
+		}
+	}
+	return append(buf, '.', '0')
+}
+
+// appendValue appends formatted value of DataPoint to buf, value could be
+// Value, any Go number, json.Number or string, that is written as is
+func appendValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case Value:
+		return v.append(buf)
+	case float64:
+		return appendFloat(buf, v, 64)
+	case float32:
+		return appendFloat(buf, float64(v), 32)
+	case json.Number:
+		return append(buf, v...)
+	case string:
+		return append(buf, v...)
+	}
+	if v, err := ParseValue(value); err == nil {
+		return v.append(buf)
+	}
+	return append(buf, fmt.Sprint(value)...)
+}
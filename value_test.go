@@ -0,0 +1,82 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueString(t *testing.T) {
+	assert.Equal(t, "1", Int(1).String())
+	assert.Equal(t, "9223372036854775807", Int(math.MaxInt64).String())
+	assert.Equal(t, "1.0", Float(1).String())
+	assert.Equal(t, "0.1", Float(0.1).String())
+	assert.Equal(t, "-2.5e-07", Float(-0.00000025).String())
+	assert.Equal(t, "1e+21", Float(1e21).String())
+	a, b := 0.1, 0.2
+	assert.Equal(t, "0.30000000000000004", Float(a+b).String())
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in       interface{}
+		expected Value
+	}{
+		{1, Int(1)},
+		{int8(-8), Int(-8)},
+		{uint32(32), Int(32)},
+		{uint64(math.MaxInt64), Int(math.MaxInt64)},
+		{float32(0.1), Float(0.1)},
+		{2.0, Float(2)},
+		{json.Number("42"), Int(42)},
+		{json.Number("42.0"), Float(42)},
+		{"1e3", Float(1000)},
+		{"-7", Int(-7)},
+		{Float(1.5), Float(1.5)},
+	}
+	for _, test := range tests {
+		v, err := ParseValue(test.in)
+		assert.NoError(t, err, "%#v", test.in)
+		assert.Equal(t, test.expected, v, "%#v", test.in)
+	}
+
+	for _, in := range []interface{}{"high", nil, true, uint64(math.MaxUint64), []int{1}} {
+		_, err := ParseValue(in)
+		assert.Error(t, err, "%#v", in)
+	}
+}
+
+func TestValueJSON(t *testing.T) {
+	data, err := json.Marshal([]Value{Int(1), Float(1), Float(0.25)})
+	assert.NoError(t, err)
+	assert.Equal(t, "[1,1.0,0.25]", string(data))
+
+	_, err = json.Marshal(Float(math.NaN()))
+	assert.Error(t, err)
+
+	var values []Value
+	assert.NoError(t, json.Unmarshal([]byte(`[1,1.0,"2.5",9223372036854775807]`), &values))
+	assert.Equal(t, []Value{Int(1), Float(1), Float(2.5), Int(math.MaxInt64)}, values)
+	assert.Error(t, json.Unmarshal([]byte(`["high"]`), &values))
+}
+
+func TestDataPointEncoding(t *testing.T) {
+	dps := DataPoints{
+		&DataPoint{"test1", 123, 1, Tags{"a": "b"}},
+		&DataPoint{"test1", 123, 2.0, Tags{"a": "b"}},
+		&DataPoint{"test1", 123, Int(math.MaxInt64), Tags{"a": "b"}},
+		&DataPoint{"test1", 123, "42", Tags{"a": "b"}},
+	}
+	data, err := json.Marshal(dps)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"metric":"test1","timestamp":123,"value":1,"tags":{"a":"b"}},`+
+		`{"metric":"test1","timestamp":123,"value":2.0,"tags":{"a":"b"}},`+
+		`{"metric":"test1","timestamp":123,"value":9223372036854775807,"tags":{"a":"b"}},`+
+		`{"metric":"test1","timestamp":123,"value":"42","tags":{"a":"b"}}]`, string(data))
+
+	assert.Equal(t, "test1 123 1 a=b", dps[0].String())
+	assert.Equal(t, "test1 123 2.0 a=b", dps[1].String())
+	assert.Equal(t, "test1 123 9223372036854775807 a=b", dps[2].String())
+}
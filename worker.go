@@ -0,0 +1,239 @@
+package opentsdb
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// worker consumes client.Queue, groups datapoints into batches and sends them
+// with its own Sender
+type worker struct {
+	client    *Client
+	sender    Sender
+	batchSize int
+	timeout   time.Duration
+	flushes   chan *flushRequest
+
+	buffer DataPoints
+	prev   int64
+	// size is encoded size of buffer in bytes without brackets, it's tracked
+	// only if client has MaxBatchBytes
+	size    int
+	encoder jsonEncoder
+}
+
+// flushRequest asks worker to send everything it holds. If final is set,
+// worker will drain whole client.Queue without requeuing and then exit
+type flushRequest struct {
+	ctx   context.Context
+	final bool
+	reply chan *flushResult
+}
+
+type flushResult struct {
+	batches int
+	failed  int
+	lost    int
+	err     error
+}
+
+func newWorker(client *Client, batchSize int, timeout time.Duration) *worker {
+	return &worker{
+		client:    client,
+		sender:    client.senders(),
+		batchSize: batchSize,
+		timeout:   timeout,
+		flushes:   make(chan *flushRequest),
+		buffer:    make(DataPoints, 0),
+	}
+}
+
+func (w *worker) run() {
+	defer w.client.wg.Done()
+	if closer, ok := w.sender.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// in-flight requests are cancelled once client is done
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-w.client.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			w.client.report(w.send(ctx, w.cut(), true))
+			timer.Reset(w.timeout)
+
+		case dp := <-w.client.Queue:
+			for _, batch := range w.add(dp) {
+				w.client.report(w.send(ctx, batch, true))
+			}
+			timer.Reset(w.timeout)
+
+		case req := <-w.flushes:
+			req.reply <- w.flush(req)
+			if req.final {
+				return
+			}
+
+		case <-w.client.done:
+			// worker that didn't get final flush request returns its buffer
+			// to Queue, so Close could spool it or count it as lost
+			for _, dp := range w.cut() {
+				w.client.enqueue(dp, DropNewest, 0)
+			}
+			return
+		}
+	}
+}
+
+// add appends dp to buffer and returns batches that are ready to be sent:
+// previous buffer if dp starts new second or doesn't fit into
+// client.MaxBatchBytes, and current one if it reached batchSize. Timestamps
+// are compared in seconds, so datapoints with second and millisecond
+// precision are batched together
+func (w *worker) add(dp *DataPoint) []DataPoints {
+	var ready []DataPoints
+	if ts := dp.seconds(); ts > w.prev {
+		if batch := w.cut(); batch != nil {
+			ready = append(ready, batch)
+		}
+		w.prev = ts
+	}
+
+	maxBytes := w.client.MaxBatchBytes
+	if maxBytes > 0 {
+		size := w.measure(dp)
+		if len(w.buffer) > 0 && w.size+size+len("]\n") > maxBytes {
+			ready = append(ready, w.cut())
+		}
+		w.size += size
+	}
+
+	w.buffer = append(w.buffer, dp)
+	if len(w.buffer) >= w.batchSize || (maxBytes > 0 && w.size+len("]\n") >= maxBytes) {
+		ready = append(ready, w.cut())
+	}
+	return ready
+}
+
+// measure returns how much dp adds to encoded batch, including separator or
+// opening bracket
+func (w *worker) measure(dp *DataPoint) int {
+	w.encoder.buf = w.encoder.buf[:0]
+	w.encoder.appendDataPoint(dp)
+	return len(w.encoder.buf) + len(",")
+}
+
+// cut returns current buffer and replaces it with empty one
+func (w *worker) cut() DataPoints {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	batch := w.buffer
+	w.buffer = make(DataPoints, 0)
+	w.size = 0
+	return batch
+}
+
+// send will send given batch and track its duration for client.Clock.
+// If client has Retry policy, batch will be retried according to it, otherwise
+// it will be requeued to client.Queue if requeue is true
+func (w *worker) send(ctx context.Context, batch DataPoints, requeue bool) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	var err error
+	switch {
+	case w.client.Retry != nil:
+		err = w.client.retry(ctx, w.sender, batch)
+	case requeue:
+		err = w.client.send(ctx, w.sender, batch)
+	default:
+		err = w.client.deliver(ctx, w.sender, batch)
+	}
+
+	stop := time.Now()
+	if atomic.LoadInt32(&w.client.instrumented) != 0 {
+		w.client.batchSizes.Observe(float64(len(batch)))
+		w.client.latency.Observe(float64(stop.Sub(start)) / float64(time.Millisecond))
+	}
+	w.client.track(&Timer{
+		Timestamp: batch[0].seconds(),
+		Start:     start,
+		Stop:      stop,
+	})
+	return err
+}
+
+// flush sends buffer and datapoints from client.Queue. For regular flush only
+// datapoints that are in queue at the moment are taken, so requeued and newly
+// pushed ones will not keep worker busy forever. For final one queue will be
+// drained until it's empty or ctx is done
+func (w *worker) flush(req *flushRequest) *flushResult {
+	result := &flushResult{}
+	requeue := !req.final
+
+	send := func(batch DataPoints) {
+		if len(batch) == 0 {
+			return
+		}
+		if req.ctx.Err() != nil {
+			if requeue {
+				// regular flush keeps client usable, so batch is returned
+				// to Queue, and what doesn't fit is spooled or dropped
+				for _, dp := range batch {
+					w.client.requeue(dp)
+				}
+			} else {
+				result.lost += len(batch) - w.client.spill(batch)
+			}
+			return
+		}
+		result.batches++
+		if err := w.send(req.ctx, batch, requeue); err != nil {
+			result.failed++
+			result.lost += w.client.salvage(batch, err)
+			result.err = err
+		}
+	}
+
+	limit := len(w.client.Queue)
+	for i := 0; req.final || i < limit; i++ {
+		if req.ctx.Err() != nil {
+			break
+		}
+
+		var dp *DataPoint
+		select {
+		case dp = <-w.client.Queue:
+		default:
+		}
+		if dp == nil {
+			break
+		}
+
+		for _, batch := range w.add(dp) {
+			send(batch)
+		}
+	}
+	send(w.cut())
+
+	if result.err == nil && req.ctx.Err() != nil {
+		result.err = req.ctx.Err()
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package opentsdb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerBatchesMixedPrecision(t *testing.T) {
+	client := NewClientWithSenders(10, func() Sender { return &recorder{} })
+	w := newWorker(client, 10, time.Second)
+
+	assert.Len(t, w.add(&DataPoint{"test1", 1356998400, 1, nil}), 0)
+	// the same second in milliseconds doesn't start new batch
+	assert.Len(t, w.add(&DataPoint{"test1", 1356998400500, 1, nil}), 0)
+	assert.Len(t, w.add(&DataPoint{"test1", 1356998400, 1, nil}), 0)
+
+	ready := w.add(&DataPoint{"test1", 1356998401000, 1, nil})
+	assert.Len(t, ready, 1)
+	assert.Len(t, ready[0], 3)
+	assert.EqualValues(t, 1356998401, w.prev)
+}
+
+func TestWorkerBatchesBySize(t *testing.T) {
+	client := NewClientWithSenders(10, func() Sender { return &recorder{} })
+	dp := &DataPoint{"test1", 1356998400, 1, Tags{"key": "val"}}
+	// every datapoint is 72 bytes, plus separator or brackets
+	client.MaxBatchBytes = 160
+	w := newWorker(client, 10, time.Second)
+
+	assert.Len(t, w.add(dp), 0)
+	assert.Len(t, w.add(dp), 0)
+	assert.Equal(t, 146, w.size)
+
+	// the third one doesn't fit, so first two are sent
+	ready := w.add(dp)
+	assert.Len(t, ready, 1)
+	assert.Len(t, ready[0], 2)
+	assert.Equal(t, 73, w.size)
+
+	var e jsonEncoder
+	var buf bytes.Buffer
+	n, err := e.encode(&buf, ready[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 146+len("]\n"), n)
+}